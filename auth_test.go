@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testJWTSecret = []byte("test-secret")
+
+// signToken returns a bearer header for an HS256 token signed with secret
+// that expires at exp.
+func signToken(t *testing.T, secret []byte, exp time.Time) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "tester",
+		"exp": exp.Unix(),
+	}).SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return "Bearer " + token
+}
+
+func TestJWTAuth(t *testing.T) {
+	bs := newTestService(t)
+	bs.JWTSecret = testJWTSecret
+	router := setupRouter(bs)
+	body := `{"name":"Emma","author":"Austen"}`
+
+	if w := do(t, router, http.MethodGet, "/v1/book", ""); w.Code != http.StatusOK {
+		t.Errorf("GET without a token: status %d, want reads left public", w.Code)
+	}
+
+	for _, tt := range []struct {
+		name, header string
+		wantMessage  string
+	}{
+		{"missing", "", "missing bearer token"},
+		{"garbage", "Bearer not.a.token", "invalid token"},
+		{"wrong secret", signToken(t, []byte("other"), time.Now().Add(time.Hour)), "invalid token"},
+		{"expired", signToken(t, testJWTSecret, time.Now().Add(-time.Minute)), "token expired"},
+	} {
+		w := do(t, router, http.MethodPost, "/v1/book", body, "Authorization", tt.header)
+		if w.Code != http.StatusUnauthorized || errorCode(t, w) != CodeUnauthorized ||
+			!strings.Contains(w.Body.String(), tt.wantMessage) {
+			t.Errorf("%s token: status %d, body %s; want 401 %q", tt.name, w.Code, w.Body, tt.wantMessage)
+		}
+	}
+
+	valid := signToken(t, testJWTSecret, time.Now().Add(time.Hour))
+	if w := do(t, router, http.MethodPost, "/v1/book", body, "Authorization", valid); w.Code != http.StatusCreated {
+		t.Errorf("valid token: status %d, body %s", w.Code, w.Body)
+	}
+
+	unsigned, _ := jwt.New(jwt.SigningMethodNone).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if w := do(t, router, http.MethodPost, "/v1/book", body, "Authorization", "Bearer "+unsigned); w.Code != http.StatusUnauthorized {
+		t.Errorf("alg none token: status %d, want 401", w.Code)
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	bs := newTestService(t)
+	bs.APIKeys = []string{"key-one", "key-two"}
+	router := setupRouter(bs)
+
+	for _, tt := range []struct {
+		name, key  string
+		wantStatus int
+		wantBody   string
+	}{
+		{"valid key", "key-two", http.StatusOK, `"data":[]`},
+		{"unknown key", "key-three", http.StatusUnauthorized, "invalid API key"},
+		{"prefix of a key", "key-", http.StatusUnauthorized, "invalid API key"},
+		{"missing header", "", http.StatusUnauthorized, "missing API key"},
+	} {
+		w := do(t, router, http.MethodGet, "/v1/book", "", apiKeyHeader, tt.key)
+		if w.Code != tt.wantStatus || !strings.Contains(w.Body.String(), tt.wantBody) {
+			t.Errorf("%s: status %d, body %s", tt.name, w.Code, w.Body)
+		}
+	}
+}
+
+func TestAPIKeyAuthWriteMode(t *testing.T) {
+	bs := newTestService(t)
+	bs.APIKeys = []string{"key-one"}
+	bs.AuthMode = authModeWrite
+	router := setupRouter(bs)
+	body := `{"name":"Emma","author":"Austen"}`
+
+	if w := do(t, router, http.MethodGet, "/v1/book", ""); w.Code != http.StatusOK {
+		t.Errorf("read without a key: status %d, want 200", w.Code)
+	}
+	if w := do(t, router, http.MethodPost, "/v1/book", body); w.Code != http.StatusUnauthorized {
+		t.Errorf("write without a key: status %d, want 401", w.Code)
+	}
+	if w := do(t, router, http.MethodPost, "/v1/book", body, apiKeyHeader, "key-one"); w.Code != http.StatusCreated {
+		t.Errorf("write with a key: status %d, want 201", w.Code)
+	}
+}
+
+func TestWritesUnauthenticated(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		keys      []string
+		secret    string
+		wantWarns bool
+	}{
+		{"no auth", nil, "", true},
+		{"API keys", []string{"key-one"}, "", false},
+		{"JWT", nil, "secret", false},
+		{"both", []string{"key-one"}, "secret", false},
+	} {
+		bs := newTestService(t)
+		bs.APIKeys = tt.keys
+		bs.JWTSecret = []byte(tt.secret)
+
+		if got := bs.writesUnauthenticated(); got != tt.wantWarns {
+			t.Errorf("%s: writesUnauthenticated() = %v, want %v", tt.name, got, tt.wantWarns)
+		}
+	}
+}
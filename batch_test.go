@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// itemErrors returns the per-book errors of a rejected batch.
+func itemErrors(t *testing.T, w *httptest.ResponseRecorder) []ItemError {
+	t.Helper()
+
+	return decode[struct {
+		Error struct {
+			Details []ItemError `json:"details"`
+		} `json:"error"`
+	}](t, w).Error.Details
+}
+
+func TestCreateBatch(t *testing.T) {
+	router := newSeededRouter(t)
+
+	w := do(t, router, http.MethodPost, "/v1/books/batch",
+		`[{"name":"Persuasion","author":"Austen"},{"name":"Sanditon","author":"Austen"}]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", w.Code, w.Body)
+	}
+	created := decode[[]Book](t, w)
+	if len(created) != 2 || created[0].ID == "" || created[1].Name != "Sanditon" || created[1].Version != 1 {
+		t.Errorf("created %+v", created)
+	}
+	for _, book := range created {
+		if w := do(t, router, http.MethodGet, "/v1/book/"+book.ID, ""); w.Code != http.StatusOK {
+			t.Errorf("GET %s: status %d", book.ID, w.Code)
+		}
+	}
+
+	w = do(t, router, http.MethodPost, "/v1/books/batch",
+		`[{"name":"Lady Susan","author":"Austen"},{"id":"`+seedID+`","name":"Emma","author":"Austen"}]`)
+	if w.Code != http.StatusConflict || errorCode(t, w) != CodeConflict {
+		t.Fatalf("duplicate: status %d, body %s", w.Code, w.Body)
+	}
+	if details := itemErrors(t, w); len(details) != 1 || details[0].Index != 1 {
+		t.Errorf("duplicate: details %+v, want index 1", details)
+	}
+
+	if total := decode[BookPage](t, do(t, router, http.MethodGet, "/v1/book", "")).Total; total != 3 {
+		t.Errorf("%d books after the rejected batch, want 3", total)
+	}
+}
+
+func TestCreateBulk(t *testing.T) {
+	router := setupRouter(newTestService(t))
+	const id = "33333333-3333-3333-3333-333333333333"
+
+	w := do(t, router, http.MethodPost, "/v1/books/bulk",
+		`[{"name":"Emma","author":"Austen"},{"name":""},{"id":"x","name":"Dune","author":"Herbert"}]`)
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeValidationFailed {
+		t.Fatalf("invalid books: status %d, body %s", w.Code, w.Body)
+	}
+	if details := itemErrors(t, w); len(details) != 2 || details[0].Index != 1 || details[1].Index != 2 {
+		t.Errorf("invalid books: details %+v, want indexes 1 and 2", details)
+	}
+
+	w = do(t, router, http.MethodPost, "/v1/books/bulk",
+		`[{"id":"`+id+`","name":"Emma","author":"Austen"},{"name":"Dune","author":"Herbert"},{"id":"`+id+`","name":"Persuasion","author":"Austen"}]`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("duplicate in the batch: status %d, body %s", w.Code, w.Body)
+	}
+	if details := itemErrors(t, w); len(details) != 1 || details[0].Index != 2 {
+		t.Errorf("duplicate in the batch: details %+v, want index 2", details)
+	}
+
+	if total := decode[BookPage](t, do(t, router, http.MethodGet, "/v1/book", "")).Total; total != 0 {
+		t.Fatalf("%d books stored by rejected batches, want 0", total)
+	}
+
+	w = do(t, router, http.MethodPost, "/v1/books/bulk", `[{"id":"`+id+`","name":"Emma","author":"Austen"},{"name":"Dune","author":"Herbert"}]`)
+	if w.Code != http.StatusOK || len(decode[[]Book](t, w)) != 2 {
+		t.Errorf("valid batch: status %d, body %s", w.Code, w.Body)
+	}
+
+	if w := do(t, router, http.MethodPost, "/v1/books/bulk", `[]`); w.Code != http.StatusBadRequest {
+		t.Errorf("empty batch: status %d, want 400", w.Code)
+	}
+}
+
+func TestCreateBatchIsAtomic(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range storeBackends(t) {
+		store.Create(ctx, Book{ID: "taken", Name: "Emma", Author: "Austen", Version: 1})
+
+		err := store.CreateBatch(ctx, []Book{
+			{ID: "new", Name: "Dune", Author: "Herbert", Version: 1},
+			{ID: "taken", Name: "Emma", Author: "Austen", Version: 1},
+		})
+
+		var batchErr *BatchError
+		if !errors.As(err, &batchErr) || batchErr.Index != 1 || !errors.Is(err, ErrConflict) {
+			t.Errorf("%s: CreateBatch = %v, want a conflict at index 1", name, err)
+		}
+		if _, err := store.GetByID(ctx, "new"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: the batch was partly stored: %v", name, err)
+		}
+	}
+}
+
+func TestDeleteBulk(t *testing.T) {
+	router := newSeededRouter(t)
+	other := postBook(t, router, `{"name":"Persuasion","author":"Austen"}`)
+	const missing = "22222222-2222-2222-2222-222222222222"
+
+	w := do(t, router, http.MethodDelete, "/v1/books/bulk",
+		`{"ids":["`+seedID+`","`+missing+`","`+other.ID+`","`+seedID+`"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", w.Code, w.Body)
+	}
+
+	got := decode[struct {
+		Deleted  []string `json:"deleted"`
+		NotFound []string `json:"not_found"`
+	}](t, w)
+	if len(got.Deleted) != 2 || got.Deleted[0] != seedID || got.Deleted[1] != other.ID ||
+		len(got.NotFound) != 1 || got.NotFound[0] != missing {
+		t.Errorf("result %+v", got)
+	}
+
+	if total := decode[BookPage](t, do(t, router, http.MethodGet, "/v1/book", "")).Total; total != 0 {
+		t.Errorf("%d books left", total)
+	}
+
+	// Already deleted books are reported as not found.
+	w = do(t, router, http.MethodDelete, "/v1/books/bulk", `{"ids":["`+seedID+`"]}`)
+	if !strings.Contains(w.Body.String(), `"deleted":[],"not_found":["`+seedID+`"]`) {
+		t.Errorf("second delete: body %s", w.Body)
+	}
+
+	for _, body := range []string{`{"ids":[]}`, `{}`} {
+		if w := do(t, router, http.MethodDelete, "/v1/books/bulk", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", body, w.Code)
+		}
+	}
+}
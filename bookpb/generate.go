@@ -0,0 +1,5 @@
+// Package bookpb holds the protobuf messages and gRPC stubs for the book
+// API, generated from book.proto.
+package bookpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative book.proto
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAddr           = ":8080"
+	defaultLogLevel       = "info"
+	defaultTrustedProxies = "127.0.0.1,::1"
+
+	authModeAll   = "all"
+	authModeWrite = "write"
+)
+
+// resolveAddr returns the listen address. The -addr flag takes precedence
+// over the ADDR environment variable, which takes precedence over defaultAddr.
+func resolveAddr(flagAddr string) (string, error) {
+	addr := flagAddr
+	if addr == "" {
+		addr = os.Getenv("ADDR")
+	}
+	if addr == "" {
+		addr = defaultAddr
+	}
+
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return "", fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+
+	return addr, nil
+}
+
+// resolveLogLevel applies the same precedence as resolveAddr: the -loglevel
+// flag, then LOG_LEVEL, then defaultLogLevel.
+func resolveLogLevel(flagLevel string) (logrus.Level, error) {
+	level := flagLevel
+	if level == "" {
+		level = os.Getenv("LOG_LEVEL")
+	}
+	if level == "" {
+		level = defaultLogLevel
+	}
+
+	switch level {
+	case "debug", "info", "warn", "warning", "error":
+	default:
+		return 0, fmt.Errorf("invalid log level %q: must be one of debug, info, warn, error", level)
+	}
+
+	return logrus.ParseLevel(level)
+}
+
+// resolveAuthMode validates the -auth-mode flag, which decides whether API
+// keys are required on every book route or only on mutating ones.
+func resolveAuthMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return authModeAll, nil
+	case authModeAll, authModeWrite:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid auth mode %q: must be %s or %s", mode, authModeAll, authModeWrite)
+	}
+}
+
+// resolveTrustedProxies parses the -trusted-proxies flag into the IPs and
+// CIDR ranges whose X-Forwarded-For and X-Real-IP headers are believed.
+// Any client can set those headers, so listing an address that isn't
+// really a proxy lets its users pick the IP the rate limiter and logs
+// see. "none" trusts no proxy, so the peer address is always used.
+func resolveTrustedProxies(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "none" {
+		return []string{}, nil
+	}
+
+	proxies := splitList(value)
+
+	for _, proxy := range proxies {
+		if _, _, err := net.ParseCIDR(proxy); err == nil {
+			continue
+		}
+		if net.ParseIP(proxy) == nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: must be an IP or CIDR", proxy)
+		}
+	}
+
+	return proxies, nil
+}
+
+// validateBurst rejects a -burst below 1, which would build a rate limiter
+// that denies every request.
+func validateBurst(burst int) error {
+	if burst < 1 {
+		return fmt.Errorf("invalid burst %d: must be at least 1", burst)
+	}
+
+	return nil
+}
+
+// splitList parses a comma-separated flag or env value, dropping blanks.
+func splitList(value string) []string {
+	var items []string
+
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// flagOrEnv returns flagValue when it is set and the env variable otherwise.
+func flagOrEnv(flagValue, env string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+
+	return os.Getenv(env)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestResolveAddr(t *testing.T) {
+	for _, tt := range []struct {
+		flag, env string
+		want      string
+	}{
+		{"", "", defaultAddr},
+		{"", "127.0.0.1:9000", "127.0.0.1:9000"},
+		{":7000", "127.0.0.1:9000", ":7000"},
+		{"[::1]:8443", "", "[::1]:8443"},
+	} {
+		t.Setenv("ADDR", tt.env)
+
+		got, err := resolveAddr(tt.flag)
+		if err != nil || got != tt.want {
+			t.Errorf("flag %q, ADDR %q: %q, %v; want %q", tt.flag, tt.env, got, err, tt.want)
+		}
+	}
+
+	for _, flag := range []string{"8080", "localhost", "::1:8080"} {
+		t.Setenv("ADDR", "")
+		if _, err := resolveAddr(flag); err == nil {
+			t.Errorf("resolveAddr(%q) accepted an address without a port", flag)
+		}
+	}
+
+	t.Setenv("ADDR", "nonsense")
+	if _, err := resolveAddr(""); err == nil {
+		t.Error("an invalid ADDR was accepted")
+	}
+}
+
+func TestResolveLogLevel(t *testing.T) {
+	for _, tt := range []struct {
+		flag, env string
+		want      logrus.Level
+	}{
+		{"", "", logrus.InfoLevel},
+		{"", "debug", logrus.DebugLevel},
+		{"error", "debug", logrus.ErrorLevel},
+		{"warn", "", logrus.WarnLevel},
+	} {
+		t.Setenv("LOG_LEVEL", tt.env)
+
+		got, err := resolveLogLevel(tt.flag)
+		if err != nil || got != tt.want {
+			t.Errorf("flag %q, LOG_LEVEL %q: %v, %v; want %v", tt.flag, tt.env, got, err, tt.want)
+		}
+	}
+
+	t.Setenv("LOG_LEVEL", "")
+	for _, level := range []string{"verbose", "trace", "panic", "INFO"} {
+		if _, err := resolveLogLevel(level); err == nil {
+			t.Errorf("resolveLogLevel(%q) was accepted", level)
+		}
+	}
+}
+
+func TestResolveTrustedProxies(t *testing.T) {
+	for value, want := range map[string]string{
+		defaultTrustedProxies:      "127.0.0.1|::1",
+		"none":                     "",
+		" 10.0.0.0/8, 192.0.2.10 ": "10.0.0.0/8|192.0.2.10",
+	} {
+		got, err := resolveTrustedProxies(value)
+		if err != nil || got == nil || strings.Join(got, "|") != want {
+			t.Errorf("resolveTrustedProxies(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+
+	for _, value := range []string{"proxy.internal", "10.0.0.0/33", "127.0.0.1,nope"} {
+		if _, err := resolveTrustedProxies(value); err == nil {
+			t.Errorf("resolveTrustedProxies(%q) accepted an invalid proxy", value)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	bs := newTestService(t)
+	bs.CORS = CORSConfig{AllowedOrigins: []string{"https://app.example", "https://admin.example"}}
+	router := setupRouter(bs)
+
+	w := do(t, router, http.MethodOptions, "/v1/book", "",
+		"Origin", "https://admin.example",
+		"Access-Control-Request-Method", http.MethodPost,
+		"Access-Control-Request-Headers", "Content-Type")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status %d, want 204", w.Code)
+	}
+
+	for header, want := range map[string]string{
+		"Access-Control-Allow-Origin":      "https://admin.example",
+		"Access-Control-Allow-Methods":     "GET, HEAD, POST, PUT, PATCH, DELETE",
+		"Access-Control-Allow-Headers":     "Authorization, Content-Type, X-API-Key, Idempotency-Key, X-Request-ID",
+		"Access-Control-Max-Age":           "600",
+		"Access-Control-Allow-Credentials": "",
+		"Vary":                             "Origin",
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("%s %q, want %q", header, got, want)
+		}
+	}
+
+	w = do(t, router, http.MethodGet, "/v1/book", "", "Origin", "https://app.example")
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://app.example" ||
+		w.Header().Get("Access-Control-Expose-Headers") != "Location, X-Total-Count, X-Request-ID" {
+		t.Errorf("actual request headers %v", w.Header())
+	}
+}
+
+func TestCORSDeniedByDefault(t *testing.T) {
+	router := setupRouter(newTestService(t))
+
+	w := do(t, router, http.MethodGet, "/v1/book", "", "Origin", "https://app.example")
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("unconfigured CORS: status %d, headers %v", w.Code, w.Header())
+	}
+}
+
+func TestCORSDisallowedOrigin(t *testing.T) {
+	bs := newTestService(t)
+	bs.CORS = CORSConfig{AllowedOrigins: []string{"https://app.example"}}
+	router := setupRouter(bs)
+
+	w := do(t, router, http.MethodOptions, "/v1/book", "",
+		"Origin", "https://evil.example", "Access-Control-Request-Method", http.MethodDelete)
+	if w.Code != http.StatusForbidden || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("preflight: status %d, headers %v", w.Code, w.Header())
+	}
+
+	w = do(t, router, http.MethodGet, "/v1/book", "", "Origin", "https://evil.example")
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("simple request got %v", w.Header())
+	}
+}
+
+func TestCORSConfig(t *testing.T) {
+	bs := newTestService(t)
+	bs.CORS = CORSConfig{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{http.MethodGet},
+		AllowedHeaders: []string{"X-Custom"},
+	}
+	router := setupRouter(bs)
+
+	w := do(t, router, http.MethodOptions, "/v1/book", "",
+		"Origin", "https://any.example", "Access-Control-Request-Method", http.MethodGet)
+	if w.Code != http.StatusNoContent ||
+		w.Header().Get("Access-Control-Allow-Origin") != "*" ||
+		w.Header().Get("Access-Control-Allow-Methods") != "GET" ||
+		w.Header().Get("Access-Control-Allow-Headers") != "X-Custom" {
+		t.Errorf("wildcard preflight: status %d, headers %v", w.Code, w.Header())
+	}
+
+	credentialed := CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}
+	if err := credentialed.Validate(); err == nil {
+		t.Error("* with credentials was accepted")
+	}
+
+	credentialed.AllowedOrigins = []string{"https://app.example"}
+	if err := credentialed.Validate(); err != nil {
+		t.Errorf("credentials for a listed origin: %v", err)
+	}
+	bs.CORS = credentialed
+	w = do(t, setupRouter(bs), http.MethodGet, "/v1/book", "", "Origin", "https://app.example")
+	if w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("credentialed request headers %v", w.Header())
+	}
+}
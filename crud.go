@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Identifiable is implemented by every entity served through RegisterCRUD.
+type Identifiable interface {
+	GetID() string
+}
+
+// idSetter is implemented by pointers to entities whose ID the API assigns
+// on create and takes from the path on update.
+type idSetter interface {
+	SetID(id string)
+}
+
+// Store is the persistence contract a Resource needs. BookStore is a
+// superset of Store[Book], so books are served by a Resource too.
+type Store[T Identifiable] interface {
+	GetAll(ctx context.Context) ([]T, error)
+	GetByID(ctx context.Context, id string) (T, error)
+	Create(ctx context.Context, item T) error
+	Update(ctx context.Context, id string, item T) error
+	Delete(ctx context.Context, id string) error
+}
+
+var _ Store[Book] = BookStore(nil)
+
+// MemStore is an in-memory Store for any entity type.
+type MemStore[T Identifiable] struct {
+	mu    sync.RWMutex
+	items map[string]T
+}
+
+func NewMemStore[T Identifiable]() *MemStore[T] {
+	return &MemStore[T]{items: make(map[string]T)}
+}
+
+// GetAll returns the items ordered by ID.
+func (ms *MemStore[T]) GetAll(ctx context.Context) ([]T, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	items := make([]T, 0, len(ms.items))
+	for _, item := range ms.items {
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].GetID() < items[j].GetID()
+	})
+
+	return items, nil
+}
+
+func (ms *MemStore[T]) GetByID(ctx context.Context, id string) (T, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	item, ok := ms.items[id]
+	if !ok {
+		return zero, ErrNotFound
+	}
+
+	return item, nil
+}
+
+func (ms *MemStore[T]) Create(ctx context.Context, item T) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if _, exists := ms.items[item.GetID()]; exists {
+		return ErrConflict
+	}
+
+	ms.items[item.GetID()] = item
+	return nil
+}
+
+func (ms *MemStore[T]) Update(ctx context.Context, id string, item T) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if _, exists := ms.items[id]; !exists {
+		return ErrNotFound
+	}
+
+	ms.items[id] = item
+	return nil
+}
+
+func (ms *MemStore[T]) Delete(ctx context.Context, id string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if _, exists := ms.items[id]; !exists {
+		return ErrNotFound
+	}
+
+	delete(ms.items, id)
+	return nil
+}
+
+// Page is the list envelope for generic resources, matching BookPage's
+// JSON shape.
+type Page[T any] struct {
+	Data   []T `json:"data"`
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// Resource holds the handlers RegisterCRUD mounts for one entity type. The
+// hooks let an entity with more behaviour than plain storage, such as Book
+// with its soft deletes, versions, ETags and events, use the same handlers;
+// each nil hook keeps the default described on it.
+type Resource[T Identifiable] struct {
+	Store  Store[T]
+	Logger *logrus.Logger
+
+	// Name is the entity in response messages, "Record" by default.
+	Name string
+	// Path is the canonical collection path created items' Location
+	// headers point below. Empty uses the path of the create route.
+	Path string
+	// Upsert lets replace create an item at a missing ID.
+	Upsert bool
+
+	// StoreError answers a failed store call.
+	StoreError func(err error, c *gin.Context)
+	// Visible reports whether a stored item exists for the request; a
+	// hidden one is answered like a missing one. By default all are.
+	Visible func(c *gin.Context, item T) bool
+	// Filter narrows and orders the items a list returns. Its error is
+	// answered with 400.
+	Filter func(c *gin.Context, items []T) ([]T, error)
+	// Prepare readies a new item before it is stored, answering and
+	// returning false to reject it. By default the ID is assigned when
+	// T has a SetID method and must otherwise be a UUID.
+	Prepare func(c *gin.Context, item *T) bool
+	// Replace readies item to replace existing before it is stored, e.g.
+	// carrying over server-managed fields and checking versions, answering
+	// and returning false to reject it.
+	Replace func(c *gin.Context, existing T, item *T) bool
+	// Delete removes a visible item and returns what it became. By default
+	// it is removed from the store.
+	Delete func(ctx context.Context, item T) (T, error)
+	// ETag makes get send an ETag and answer a matching If-None-Match
+	// with 304.
+	ETag func(item T) (string, error)
+	// IfMatch reports whether an If-Match header permits replacing item.
+	// Without it If-Match is ignored.
+	IfMatch func(header string, item T) bool
+	// Changed is told about every item created, replaced or deleted.
+	Changed func(event string, item T)
+	// Render and RenderPage write an item and a list page. By default both
+	// are JSON.
+	Render     func(c *gin.Context, status int, item T)
+	RenderPage func(c *gin.Context, page Page[T])
+}
+
+// RegisterCRUD mounts list, get, create, replace and delete handlers for
+// store under path on r. writeMiddleware runs before the mutating handlers
+// only, so reads and writes can be authorized separately.
+func RegisterCRUD[T Identifiable](r gin.IRouter, path string, store Store[T],
+	logger *logrus.Logger, writeMiddleware ...gin.HandlerFunc) {
+	res := &Resource[T]{Store: store, Logger: logger}
+
+	r.GET(path, res.list)
+	r.GET(path+"/:id", res.get)
+
+	writes := r.Group("", writeMiddleware...)
+	writes.POST(path, res.create)
+	writes.PUT(path+"/:id", res.replace)
+	writes.DELETE(path+"/:id", res.delete)
+}
+
+func (res *Resource[T]) name() string {
+	if res.Name == "" {
+		return "Record"
+	}
+
+	return res.Name
+}
+
+func (res *Resource[T]) storeError(err error, c *gin.Context) {
+	if res.StoreError != nil {
+		res.StoreError(err, c)
+		return
+	}
+
+	switch {
+	case errors.Is(err, ErrNotFound):
+		respondError(c, http.StatusNotFound, CodeNotFound, res.name()+" not found")
+	case errors.Is(err, ErrConflict):
+		respondError(c, http.StatusConflict, CodeConflict, res.name()+" with this ID already exists")
+	case isContextError(err):
+		respondTimeout(c)
+	default:
+		respondError(c, http.StatusInternalServerError, CodeInternal, "Internal server error")
+		logRequestError(res.Logger, err, c, "Error when accessing storage")
+	}
+}
+
+func (res *Resource[T]) bindError(err error, c *gin.Context) {
+	if respondBindError(c, err) {
+		logRequestError(res.Logger, err, c, "Error when decoding JSON")
+	}
+}
+
+func (res *Resource[T]) render(c *gin.Context, status int, item T) {
+	if res.Render != nil {
+		res.Render(c, status, item)
+		return
+	}
+
+	c.JSON(status, item)
+}
+
+func (res *Resource[T]) changed(event string, item T) {
+	if res.Changed != nil {
+		res.Changed(event, item)
+	}
+}
+
+// getVisible is GetByID that answers a hidden item with ErrNotFound.
+func (res *Resource[T]) getVisible(c *gin.Context, id string) (T, error) {
+	item, err := res.Store.GetByID(c.Request.Context(), id)
+	if err == nil && res.Visible != nil && !res.Visible(c, item) {
+		var zero T
+		return zero, ErrNotFound
+	}
+
+	return item, err
+}
+
+func (res *Resource[T]) list(c *gin.Context) {
+	items, err := res.Store.GetAll(c.Request.Context())
+	if err != nil {
+		res.storeError(err, c)
+		return
+	}
+
+	limit, offset, err := parsePage(c.Query("limit"), c.Query("offset"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeBadRequest, err.Error())
+		return
+	}
+
+	if res.Filter != nil {
+		if items, err = res.Filter(c, items); err != nil {
+			respondError(c, http.StatusBadRequest, CodeBadRequest, err.Error())
+			return
+		}
+	}
+
+	page := Page[T]{
+		Data:   paginate(items, limit, offset),
+		Total:  len(items),
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	if res.RenderPage != nil {
+		res.RenderPage(c, page)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func (res *Resource[T]) get(c *gin.Context) {
+	item, err := res.getVisible(c, c.Param("id"))
+	if err != nil {
+		res.storeError(err, c)
+		return
+	}
+
+	if res.ETag != nil {
+		etag, err := res.ETag(item)
+		if err != nil {
+			res.storeError(err, c)
+			return
+		}
+
+		c.Header("ETag", etag)
+
+		if etagMatches(c.GetHeader("If-None-Match"), etag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	res.render(c, http.StatusOK, item)
+}
+
+func (res *Resource[T]) create(c *gin.Context) {
+	var item T
+	if err := bindStrictJSON(c, &item); err != nil {
+		res.bindError(err, c)
+		return
+	}
+
+	res.insert(c, item)
+}
+
+// insert prepares and stores a new item and answers 201 with it.
+func (res *Resource[T]) insert(c *gin.Context, item T) {
+	if res.Prepare != nil {
+		if !res.Prepare(c, &item) {
+			return
+		}
+	} else if setter, ok := any(&item).(idSetter); ok {
+		if item.GetID() == "" {
+			setter.SetID(uuid.NewString())
+		} else if _, err := uuid.Parse(item.GetID()); err != nil {
+			respondError(c, http.StatusBadRequest, CodeValidationFailed, "ID must be a valid UUID")
+			return
+		}
+	}
+
+	if err := res.Store.Create(c.Request.Context(), item); err != nil {
+		res.storeError(err, c)
+		return
+	}
+
+	path := res.Path
+	if path == "" {
+		path = strings.TrimSuffix(c.FullPath(), "/:id")
+	}
+
+	c.Header("Location", path+"/"+item.GetID())
+	res.changed(eventCreated, item)
+	res.render(c, http.StatusCreated, item)
+}
+
+// replace overwrites the item at the path ID; any ID in the body is ignored.
+// With Upsert a missing ID is created instead (201).
+func (res *Resource[T]) replace(c *gin.Context) {
+	id := c.Param("id")
+
+	var item T
+	if err := bindStrictJSON(c, &item); err != nil {
+		res.bindError(err, c)
+		return
+	}
+
+	if setter, ok := any(&item).(idSetter); ok {
+		setter.SetID(id)
+	}
+
+	existing, err := res.Store.GetByID(c.Request.Context(), id)
+	if errors.Is(err, ErrNotFound) && res.Upsert {
+		if c.GetHeader("If-Match") != "" {
+			respondError(c, http.StatusPreconditionFailed, CodePreconditionFailed,
+				"If-Match was sent for a "+strings.ToLower(res.name())+" that does not exist")
+			return
+		}
+
+		res.insert(c, item)
+		return
+	}
+	if err == nil && res.Visible != nil && !res.Visible(c, existing) {
+		err = ErrNotFound
+	}
+	if err != nil {
+		res.storeError(err, c)
+		return
+	}
+
+	if res.IfMatch != nil && !res.IfMatch(c.GetHeader("If-Match"), existing) {
+		respondError(c, http.StatusPreconditionFailed, CodePreconditionFailed,
+			res.name()+" has been modified since the version in If-Match")
+		return
+	}
+
+	if res.Replace != nil && !res.Replace(c, existing, &item) {
+		return
+	}
+
+	if err := res.Store.Update(c.Request.Context(), id, item); err != nil {
+		res.storeError(err, c)
+		return
+	}
+
+	res.changed(eventUpdated, item)
+	res.render(c, http.StatusOK, item)
+}
+
+func (res *Resource[T]) delete(c *gin.Context) {
+	item, err := res.getVisible(c, c.Param("id"))
+	if err == nil {
+		if res.Delete != nil {
+			item, err = res.Delete(c.Request.Context(), item)
+		} else {
+			err = res.Store.Delete(c.Request.Context(), item.GetID())
+		}
+	}
+	if err != nil {
+		res.storeError(err, c)
+		return
+	}
+
+	res.changed(eventDeleted, item)
+	c.JSON(http.StatusOK, gin.H{"message": res.name() + " deleted successfully"})
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// gadget is an entity that exists only in tests, to show RegisterCRUD works
+// for any Identifiable type.
+type gadget struct {
+	ID   string `json:"id"`
+	Name string `json:"name" binding:"required"`
+}
+
+func (g gadget) GetID() string    { return g.ID }
+func (g *gadget) SetID(id string) { g.ID = id }
+
+func TestRegisterCRUDLifecycle(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	router := gin.New()
+	RegisterCRUD(router, "/gadget", Store[gadget](NewMemStore[gadget]()), logger)
+
+	w := do(t, router, http.MethodPost, "/gadget", `{"name":"Lamp"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status %d, body %s", w.Code, w.Body)
+	}
+	created := decode[gadget](t, w)
+	path := "/gadget/" + created.ID
+	if created.ID == "" || w.Header().Get("Location") != path {
+		t.Errorf("create = %+v, Location %q", created, w.Header().Get("Location"))
+	}
+
+	if w := do(t, router, http.MethodGet, path, ""); w.Code != http.StatusOK || decode[gadget](t, w) != created {
+		t.Errorf("get: status %d, body %s", w.Code, w.Body)
+	}
+
+	w = do(t, router, http.MethodGet, "/gadget", "")
+	if page := decode[Page[gadget]](t, w); w.Code != http.StatusOK || page.Total != 1 || page.Data[0] != created {
+		t.Errorf("list: status %d, body %s", w.Code, w.Body)
+	}
+
+	w = do(t, router, http.MethodPut, path, `{"id":"ignored","name":"Desk lamp"}`)
+	if got := decode[gadget](t, w); w.Code != http.StatusOK || got != (gadget{ID: created.ID, Name: "Desk lamp"}) {
+		t.Errorf("replace: status %d, body %s", w.Code, w.Body)
+	}
+
+	if w := do(t, router, http.MethodDelete, path, ""); w.Code != http.StatusOK {
+		t.Errorf("delete: status %d", w.Code)
+	}
+	if w := do(t, router, http.MethodGet, path, ""); w.Code != http.StatusNotFound || errorCode(t, w) != CodeNotFound {
+		t.Errorf("get after delete: status %d, body %s", w.Code, w.Body)
+	}
+
+	for _, tt := range []struct {
+		name, method, path, body string
+		wantStatus               int
+	}{
+		{"create bad JSON", http.MethodPost, "/gadget", `{"name":`, http.StatusBadRequest},
+		{"create without name", http.MethodPost, "/gadget", `{}`, http.StatusBadRequest},
+		{"create with invalid ID", http.MethodPost, "/gadget", `{"id":"x","name":"Lamp"}`, http.StatusBadRequest},
+		{"replace missing", http.MethodPut, path, `{"name":"Lamp"}`, http.StatusNotFound},
+		{"delete missing", http.MethodDelete, path, "", http.StatusNotFound},
+	} {
+		if w := do(t, router, tt.method, tt.path, tt.body); w.Code != tt.wantStatus {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.wantStatus)
+		}
+	}
+
+	do(t, router, http.MethodPost, "/gadget", `{"id":"`+seedID+`","name":"Lamp"}`)
+	if w := do(t, router, http.MethodPost, "/gadget", `{"id":"`+seedID+`","name":"Lamp"}`); w.Code != http.StatusConflict {
+		t.Errorf("create duplicate: status %d, want 409", w.Code)
+	}
+}
+
+func TestResourceHooks(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	var events []string
+	res := &Resource[gadget]{
+		Store:  NewMemStore[gadget](),
+		Logger: logger,
+		Name:   "Gadget",
+		Path:   "/v1/gadget",
+		Upsert: true,
+		IfMatch: func(header string, g gadget) bool {
+			return header == "" || header == g.Name
+		},
+		Changed: func(event string, g gadget) {
+			events = append(events, event+" "+g.Name)
+		},
+	}
+
+	router := gin.New()
+	router.PUT("/gadget/:id", res.replace)
+	router.DELETE("/gadget/:id", res.delete)
+	path := "/gadget/" + seedID
+
+	w := do(t, router, http.MethodPut, path, `{"name":"Lamp"}`, "If-Match", "Lamp")
+	if w.Code != http.StatusPreconditionFailed || errorCode(t, w) != CodePreconditionFailed {
+		t.Errorf("upsert with If-Match: status %d, body %s", w.Code, w.Body)
+	}
+
+	w = do(t, router, http.MethodPut, path, `{"name":"Lamp"}`)
+	if w.Code != http.StatusCreated || w.Header().Get("Location") != "/v1"+path {
+		t.Errorf("upsert: status %d, Location %q", w.Code, w.Header().Get("Location"))
+	}
+
+	w = do(t, router, http.MethodPut, path, `{"name":"Desk lamp"}`, "If-Match", "Desk lamp")
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("replace with stale If-Match: status %d, want 412", w.Code)
+	}
+
+	if w := do(t, router, http.MethodPut, path, `{"name":"Desk lamp"}`, "If-Match", "Lamp"); w.Code != http.StatusOK {
+		t.Errorf("replace: status %d, body %s", w.Code, w.Body)
+	}
+
+	w = do(t, router, http.MethodDelete, path, "")
+	if msg := decode[map[string]string](t, w)["message"]; w.Code != http.StatusOK || msg != "Gadget deleted successfully" {
+		t.Errorf("delete: status %d, body %s", w.Code, w.Body)
+	}
+
+	want := []string{"created Lamp", "updated Desk lamp", "deleted Desk lamp"}
+	if !slices.Equal(events, want) {
+		t.Errorf("events = %q, want %q", events, want)
+	}
+}
+
+func TestEntityStoresUseTheBookBackend(t *testing.T) {
+	dir := t.TempDir()
+
+	backends := map[string]func() (BookStore, error){
+		"sqlite": func() (BookStore, error) { return NewSQLiteStore(filepath.Join(dir, "books.db")) },
+		"file":   func() (BookStore, error) { return NewJSONFileStore(filepath.Join(dir, "books.json")) },
+	}
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			author := Author{ID: seedID, Name: "Austen"}
+
+			books, err := open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			authors, err := openEntityStore[Author](books, "authors")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := authors.Create(ctx, author); err != nil {
+				t.Fatal(err)
+			}
+			if err := authors.Create(ctx, author); err != ErrConflict {
+				t.Errorf("duplicate create: %v, want ErrConflict", err)
+			}
+			if err := authors.Update(ctx, "missing", author); err != ErrNotFound {
+				t.Errorf("update of a missing author: %v, want ErrNotFound", err)
+			}
+
+			if closer, ok := books.(io.Closer); ok {
+				closer.Close()
+			}
+
+			books, err = open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if closer, ok := books.(io.Closer); ok {
+				defer closer.Close()
+			}
+			authors, err = openEntityStore[Author](books, "authors")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, err := authors.GetByID(ctx, seedID); err != nil || got != author {
+				t.Errorf("after reopening: %+v, %v; want %+v", got, err, author)
+			}
+
+			if err := authors.Delete(ctx, seedID); err != nil {
+				t.Fatal(err)
+			}
+			if all, err := authors.GetAll(ctx); err != nil || len(all) != 0 {
+				t.Errorf("after delete: %v, %v", all, err)
+			}
+		})
+	}
+}
+
+func TestPGPlaceholders(t *testing.T) {
+	got := pgPlaceholders(`UPDATE authors SET data = ? WHERE id = ?`)
+	if want := `UPDATE authors SET data = $1 WHERE id = $2`; got != want {
+		t.Errorf("pgPlaceholders = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+type Author struct {
+	ID   string `json:"id"`
+	Name string `json:"name" binding:"required,max=200"`
+}
+
+func (a Author) GetID() string    { return a.ID }
+func (a *Author) SetID(id string) { a.ID = id }
+
+type Publisher struct {
+	ID      string `json:"id"`
+	Name    string `json:"name" binding:"required,max=200"`
+	Country string `json:"country" binding:"max=100"`
+}
+
+func (p Publisher) GetID() string    { return p.ID }
+func (p *Publisher) SetID(id string) { p.ID = id }
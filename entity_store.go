@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// openEntityStore returns the Store for the entity type named by table,
+// kept in the same backend as books: a table in the same database, a JSON
+// file next to the books' data file, or memory.
+func openEntityStore[T Identifiable](books BookStore, table string) (Store[T], error) {
+	switch store := books.(type) {
+	case *SQLiteStore:
+		return newSQLEntityStore[T](store.DB, table, func(q string) string { return q }, sqliteError)
+	case *PostgresStore:
+		return newSQLEntityStore[T](store.DB, table, pgPlaceholders, pgError)
+	case *JSONFileStore:
+		ext := filepath.Ext(store.Path)
+		return newFileEntityStore[T](strings.TrimSuffix(store.Path, ext) + "-" + table + ext)
+	default:
+		return NewMemStore[T](), nil
+	}
+}
+
+// sqlEntityStore keeps each entity as a JSON document keyed by its ID, so new
+// entity types need no schema of their own.
+type sqlEntityStore[T Identifiable] struct {
+	db    *sql.DB
+	table string
+	// bind rewrites the ? placeholders of a query for the driver.
+	bind func(query string) string
+	// mapError maps driver errors to the store errors.
+	mapError func(error) error
+}
+
+func newSQLEntityStore[T Identifiable](db *sql.DB, table string, bind func(string) string,
+	mapError func(error) error) (*sqlEntityStore[T], error) {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS ` + table + ` (
+		id TEXT PRIMARY KEY,
+		data TEXT NOT NULL
+	)`); err != nil {
+		return nil, err
+	}
+
+	return &sqlEntityStore[T]{db: db, table: table, bind: bind, mapError: mapError}, nil
+}
+
+// pgPlaceholders numbers the ? placeholders of query as $1, $2 and so on.
+func pgPlaceholders(query string) string {
+	var b strings.Builder
+	n := 0
+
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// GetAll returns the items ordered by ID.
+func (ss *sqlEntityStore[T]) GetAll(ctx context.Context) ([]T, error) {
+	rows, err := ss.db.QueryContext(ctx, `SELECT data FROM `+ss.table+` ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []T{}
+
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		var item T
+		if err := json.Unmarshal([]byte(data), &item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+func (ss *sqlEntityStore[T]) GetByID(ctx context.Context, id string) (T, error) {
+	var item T
+	var data string
+
+	err := ss.db.QueryRowContext(ctx, ss.bind(`SELECT data FROM `+ss.table+` WHERE id = ?`), id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return item, ErrNotFound
+	}
+	if err != nil {
+		return item, err
+	}
+
+	return item, json.Unmarshal([]byte(data), &item)
+}
+
+func (ss *sqlEntityStore[T]) Create(ctx context.Context, item T) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	_, err = ss.db.ExecContext(ctx, ss.bind(`INSERT INTO `+ss.table+` (id, data) VALUES (?, ?)`),
+		item.GetID(), string(data))
+
+	return ss.mapError(err)
+}
+
+func (ss *sqlEntityStore[T]) Update(ctx context.Context, id string, item T) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	res, err := ss.db.ExecContext(ctx, ss.bind(`UPDATE `+ss.table+` SET data = ? WHERE id = ?`), string(data), id)
+	if err != nil {
+		return ss.mapError(err)
+	}
+
+	return checkAffected(res)
+}
+
+func (ss *sqlEntityStore[T]) Delete(ctx context.Context, id string) error {
+	res, err := ss.db.ExecContext(ctx, ss.bind(`DELETE FROM `+ss.table+` WHERE id = ?`), id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// fileEntityStore is a MemStore that rewrites its JSON file after every
+// change, like JSONFileStore does for books. mu serialises the writes so
+// each save sees the change it follows.
+type fileEntityStore[T Identifiable] struct {
+	*MemStore[T]
+	path string
+	mu   sync.Mutex
+}
+
+func newFileEntityStore[T Identifiable](path string) (*fileEntityStore[T], error) {
+	fs := &fileEntityStore[T]{MemStore: NewMemStore[T](), path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &fs.items); err != nil {
+		return nil, err
+	}
+
+	return fs, nil
+}
+
+func (fs *fileEntityStore[T]) save() error {
+	fs.MemStore.mu.RLock()
+	defer fs.MemStore.mu.RUnlock()
+
+	return writeJSONFile(fs.path, fs.items)
+}
+
+func (fs *fileEntityStore[T]) Create(ctx context.Context, item T) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.MemStore.Create(ctx, item); err != nil {
+		return err
+	}
+
+	if err := fs.save(); err != nil {
+		fs.MemStore.Delete(context.WithoutCancel(ctx), item.GetID())
+		return err
+	}
+
+	return nil
+}
+
+func (fs *fileEntityStore[T]) Update(ctx context.Context, id string, item T) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	old, err := fs.MemStore.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.MemStore.Update(ctx, id, item); err != nil {
+		return err
+	}
+
+	if err := fs.save(); err != nil {
+		fs.MemStore.Update(context.WithoutCancel(ctx), id, old)
+		return err
+	}
+
+	return nil
+}
+
+func (fs *fileEntityStore[T]) Delete(ctx context.Context, id string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	old, err := fs.MemStore.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.MemStore.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	if err := fs.save(); err != nil {
+		fs.MemStore.Create(context.WithoutCancel(ctx), old)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	CodeBadRequest         = "BAD_REQUEST"
+	CodeInvalidJSON        = "INVALID_JSON"
+	CodeValidationFailed   = "VALIDATION_FAILED"
+	CodeUnauthorized       = "UNAUTHORIZED"
+	CodeNotFound           = "NOT_FOUND"
+	CodeConflict           = "CONFLICT"
+	CodeIdempotencyReused  = "IDEMPOTENCY_KEY_REUSED"
+	CodePreconditionFailed = "PRECONDITION_FAILED"
+	CodePayloadTooLarge    = "PAYLOAD_TOO_LARGE"
+	CodeUnsupportedMedia   = "UNSUPPORTED_MEDIA_TYPE"
+	CodeRateLimited        = "RATE_LIMITED"
+	CodeRequestTimeout     = "REQUEST_TIMEOUT"
+	CodeInternal           = "INTERNAL_ERROR"
+)
+
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
+
+func respondError(c *gin.Context, status int, code, message string) {
+	respondErrorDetails(c, status, code, message, nil)
+}
+
+func respondErrorDetails(c *gin.Context, status int, code, message string, details any) {
+	c.AbortWithStatusJSON(status, gin.H{"error": APIError{
+		Code:    code,
+		Message: message,
+		Details: details,
+	}})
+}
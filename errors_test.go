@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestErrorEnvelope(t *testing.T) {
+	router := setupRouter(newTestService(t))
+
+	w := do(t, router, http.MethodGet, "/v1/book/"+seedID, "")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("Content-Type %q", ct)
+	}
+	if want := `{"error":{"code":"NOT_FOUND","message":"Record not found"}}`; w.Body.String() != want {
+		t.Errorf("body %s, want %s", w.Body, want)
+	}
+
+	w = do(t, router, http.MethodPost, "/v1/book", `{"name":"Emma"}`)
+	want := `{"error":{"code":"VALIDATION_FAILED","message":"Validation failed: author is required","details":["author is required"]}}`
+	if w.Body.String() != want {
+		t.Errorf("validation body %s, want %s", w.Body, want)
+	}
+}
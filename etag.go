@@ -0,0 +1,60 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+func bookETag(book Book) (string, error) {
+	data, err := json.Marshal(book)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// etagMatches reports whether an If-None-Match style header lists etag,
+// using the weak comparison RFC 9110 prescribes for conditional GETs.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ifMatch reports whether an If-Match header permits an update of book. It
+// accepts the ETag returned by GET as well as the bare book version; an
+// absent header or "*" always matches.
+func ifMatch(header string, book Book) bool {
+	if header == "" {
+		return true
+	}
+
+	etag, err := bookETag(book)
+	if err != nil {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+
+		if v, err := strconv.Atoi(strings.Trim(candidate, `"`)); err == nil && v == book.Version {
+			return true
+		}
+	}
+
+	return false
+}
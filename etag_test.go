@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestConditionalGet(t *testing.T) {
+	router := newSeededRouter(t)
+	path := "/v1/book/" + seedID
+
+	w := do(t, router, http.MethodGet, path, "")
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+
+	for _, header := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		w := do(t, router, http.MethodGet, path, "", "If-None-Match", header)
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("ETag") != etag {
+			t.Errorf("If-None-Match %s: status %d, body %q", header, w.Code, w.Body)
+		}
+	}
+
+	do(t, router, http.MethodPatch, path, `{"author":"Jane Austen"}`)
+
+	w = do(t, router, http.MethodGet, path, "", "If-None-Match", etag)
+	if w.Code != http.StatusOK {
+		t.Errorf("after an update: status %d, want 200", w.Code)
+	}
+	if w.Header().Get("ETag") == etag {
+		t.Error("ETag didn't change when the book did")
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	for _, method := range []string{http.MethodPut, http.MethodPatch} {
+		router := newSeededRouter(t)
+		path := "/v1/book/" + seedID
+		body := `{"name":"Emma","author":"Jane Austen"}`
+
+		etag := do(t, router, http.MethodGet, path, "").Header().Get("ETag")
+
+		w := do(t, router, method, path, body, "If-Match", etag)
+		if w.Code != http.StatusOK || decode[Book](t, w).Version != 2 {
+			t.Fatalf("%s with the current ETag: status %d, body %s", method, w.Code, w.Body)
+		}
+
+		for _, stale := range []string{etag, "1", `"1"`} {
+			w := do(t, router, method, path, body, "If-Match", stale)
+			if w.Code != http.StatusPreconditionFailed || errorCode(t, w) != CodePreconditionFailed {
+				t.Errorf("%s with stale If-Match %s: status %d, body %s", method, stale, w.Code, w.Body)
+			}
+		}
+
+		if w := do(t, router, method, path, body, "If-Match", "2"); w.Code != http.StatusOK {
+			t.Errorf("%s with the current version: status %d, body %s", method, w.Code, w.Body)
+		}
+		if got := decode[Book](t, do(t, router, http.MethodGet, path, "")); got.Version != 3 {
+			t.Errorf("%s: version %d after two accepted updates, want 3", method, got.Version)
+		}
+	}
+}
+
+func TestBookETag(t *testing.T) {
+	router := newSeededRouter(t)
+	path := "/v1/book/" + seedID
+
+	w := do(t, router, http.MethodGet, path, "")
+	etag := w.Header().Get("ETag")
+	want, err := bookETag(decode[Book](t, w))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || etag != want {
+		t.Fatalf("status %d, ETag %q; want 200 with the hash of the book %s", w.Code, etag, want)
+	}
+	if again := do(t, router, http.MethodGet, path, "").Header().Get("ETag"); again != etag {
+		t.Errorf("ETag changed from %s to %s without an update", etag, again)
+	}
+
+	w = do(t, router, http.MethodGet, path, "", "If-None-Match", etag)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("If-None-Match: status %d, body %q; want an empty 304", w.Code, w.Body)
+	}
+	if w := do(t, router, http.MethodGet, path, "", "If-None-Match", `"stale"`); w.Code != http.StatusOK {
+		t.Errorf("non-matching If-None-Match: status %d, want 200", w.Code)
+	}
+
+	if w := do(t, router, http.MethodPut, path, `{"name":"Emma","author":"Jane Austen"}`, "If-Match", etag); w.Code != http.StatusOK {
+		t.Fatalf("PUT with the GET ETag: status %d, body %s", w.Code, w.Body)
+	}
+	if got := do(t, router, http.MethodGet, path, "").Header().Get("ETag"); got == etag {
+		t.Error("ETag unchanged after the update")
+	}
+	if w := do(t, router, http.MethodPut, path, `{"name":"Emma","author":"J. Austen"}`, "If-Match", etag); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("PUT with the old ETag: status %d, want 412", w.Code)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"sync"
+)
+
+const (
+	eventCreated = "created"
+	eventUpdated = "updated"
+	eventDeleted = "deleted"
+)
+
+// defaultEventBuffer is how many events a subscriber may fall behind by
+// before it is dropped.
+const defaultEventBuffer = 64
+
+type BookEvent struct {
+	Type string `json:"type"`
+	Book Book   `json:"book"`
+}
+
+// EventHub fans book changes out to every subscriber. Publish never blocks:
+// a subscriber whose buffer is full is dropped and its channel closed, so a
+// slow client can't hold up writes or other clients.
+type EventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan BookEvent]struct{}
+	buffer      int
+	// done is closed by Close.
+	done chan struct{}
+}
+
+func NewEventHub(buffer int) *EventHub {
+	return &EventHub{
+		subscribers: make(map[chan BookEvent]struct{}),
+		buffer:      buffer,
+		done:        make(chan struct{}),
+	}
+}
+
+// Subscribe returns a channel of events published from now on and a
+// function that ends the subscription. The channel is closed when the
+// subscription ends, whether by unsubscribe, by being dropped or by the hub
+// closing; after Close it is returned already closed.
+func (h *EventHub) Subscribe() (events <-chan BookEvent, unsubscribe func()) {
+	ch := make(chan BookEvent, h.buffer)
+
+	h.mu.Lock()
+	select {
+	case <-h.done:
+		close(ch)
+	default:
+		h.subscribers[ch] = struct{}{}
+	}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		h.removeLocked(ch)
+	}
+}
+
+func (h *EventHub) Publish(event BookEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+			h.removeLocked(ch)
+		}
+	}
+}
+
+// Close ends every subscription, so streaming handlers return and the
+// server's shutdown doesn't wait on them. It is safe to call more than once.
+func (h *EventHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	select {
+	case <-h.done:
+		return
+	default:
+		close(h.done)
+	}
+
+	for ch := range h.subscribers {
+		h.removeLocked(ch)
+	}
+}
+
+// Done returns a channel that is closed once Close has been called.
+func (h *EventHub) Done() <-chan struct{} {
+	return h.done
+}
+
+func (h *EventHub) removeLocked(ch chan BookEvent) {
+	if _, ok := h.subscribers[ch]; ok {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publish sends an event for book if the service has a hub. Books are
+// cloned so subscribers can't share memory with the handler.
+func (bs *BookService) publish(eventType string, books ...Book) {
+	if bs.Events == nil {
+		return
+	}
+
+	for _, book := range books {
+		bs.Events.Publish(BookEvent{Type: eventType, Book: book.clone()})
+	}
+}
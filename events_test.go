@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestEventHubPublish(t *testing.T) {
+	hub := NewEventHub(1)
+
+	fast, unsubscribe := hub.Subscribe()
+	defer unsubscribe()
+	slow, _ := hub.Subscribe()
+
+	hub.Publish(BookEvent{Type: eventCreated, Book: Book{ID: "a"}})
+	<-fast
+	hub.Publish(BookEvent{Type: eventUpdated, Book: Book{ID: "a"}})
+
+	if event := <-fast; event.Type != eventUpdated {
+		t.Errorf("fast subscriber got %+v", event)
+	}
+
+	<-slow
+	if _, ok := <-slow; ok {
+		t.Error("a subscriber with a full buffer was not dropped")
+	}
+}
+
+func TestEventHubClose(t *testing.T) {
+	hub := NewEventHub(1)
+	events, unsubscribe := hub.Subscribe()
+
+	hub.Close()
+	hub.Close()
+
+	if _, ok := <-events; ok {
+		t.Error("subscription still open after Close")
+	}
+	unsubscribe()
+
+	select {
+	case <-hub.Done():
+	default:
+		t.Error("Done not closed after Close")
+	}
+
+	late, _ := hub.Subscribe()
+	if _, ok := <-late; ok {
+		t.Error("Subscribe after Close returned an open channel")
+	}
+
+	// Publishing to a closed hub must not block or panic.
+	hub.Publish(BookEvent{Type: eventCreated})
+}
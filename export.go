@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportBooks downloads the whole catalog as ?format=json (the default) or
+// csv, under a filename stamped with the export time.
+func (bs *BookService) exportBooks(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		respondError(c, http.StatusBadRequest, CodeBadRequest, "format must be json or csv")
+		return
+	}
+
+	bs.export(c, format, "books-"+bs.now().Format("20060102T150405Z")+"."+format)
+}
+
+func (bs *BookService) exportBooksCSV(c *gin.Context) {
+	bs.export(c, "csv", "books.csv")
+}
+
+// export writes every book, filtered and sorted like the list endpoint but
+// without pagination, as an attachment. Books are encoded one at a time
+// straight to the response rather than building the body in memory.
+func (bs *BookService) export(c *gin.Context, format, filename string) {
+	books, err := bs.Store.GetAll(c.Request.Context())
+	if err != nil {
+		bs.storeError(err, c)
+		return
+	}
+
+	books = filterBooks(books, bookFilterFromQuery(c))
+
+	if err := sortBooks(books, c.Query("sort")); err != nil {
+		respondError(c, http.StatusBadRequest, CodeBadRequest, err.Error())
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	if format == "csv" {
+		renderCSV(c, http.StatusOK, books)
+		return
+	}
+
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	if err := writeBooksJSON(c.Writer, books); err != nil {
+		c.Error(err)
+	}
+}
+
+func writeBooksJSON(w http.ResponseWriter, books []Book) error {
+	if _, err := w.Write([]byte("[")); err != nil {
+		return err
+	}
+
+	for i, book := range books {
+		data, err := json.Marshal(book)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			data = append([]byte(",\n"), data...)
+		}
+
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write([]byte("]\n"))
+	return err
+}
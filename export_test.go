@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExportCSV(t *testing.T) {
+	router := newLibraryRouter(t)
+
+	w := do(t, router, http.MethodGet, "/v1/book/export.csv?name=u&sort=-name", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type %q", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename="books.csv"` {
+		t.Errorf("Content-Disposition %q", cd)
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []string
+	for _, record := range records {
+		rows = append(rows, strings.Join(record[:3], "|"))
+	}
+	want := "id|name|author," +
+		"22222222-2222-2222-2222-222222222222|Persuasion|Jane Austen," +
+		"11111111-1111-1111-1111-111111111111|Dune|Frank Herbert"
+	if got := strings.Join(rows, ","); got != want {
+		t.Errorf("rows\n%s\nwant\n%s", got, want)
+	}
+
+	bs := newTestService(t)
+	seedBooks(t, bs, 120)
+	records, err = csv.NewReader(do(t, setupRouter(bs), http.MethodGet, "/v1/book/export.csv", "").Body).ReadAll()
+	if err != nil || len(records) != 121 {
+		t.Errorf("%d records, %v; want every book past the page size", len(records), err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	router := newClockedRouter(t)
+	books := decode[BookPage](t, do(t, router, http.MethodGet, "/v1/book", "")).Data
+
+	w := do(t, router, http.MethodGet, "/v1/book/export", "")
+	if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename="books-20240501T120000Z.json"` {
+		t.Errorf("Content-Disposition %q", cd)
+	}
+	if got := decode[[]Book](t, w); !reflect.DeepEqual(got, books) {
+		t.Errorf("exported\n%+v\nwant\n%+v", got, books)
+	}
+
+	w = do(t, router, http.MethodGet, "/v1/book/export?format=csv&sort=-name", "")
+	if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename="books-20240501T120000Z.csv"` {
+		t.Errorf("CSV Content-Disposition %q", cd)
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil || len(records) != 3 || records[1][1] != "Emma" || records[2][1] != "Dune, Messiah" {
+		t.Errorf("CSV export %q, %v", records, err)
+	}
+
+	exported := decode[[]Book](t, do(t, router, http.MethodGet, "/v1/book/export?author=herbert", ""))
+	if len(exported) != 1 || exported[0].Name != "Dune, Messiah" {
+		t.Errorf("filtered export %+v", exported)
+	}
+
+	if w := do(t, router, http.MethodGet, "/v1/book/export?format=xml", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("format=xml: status %d, want 400", w.Code)
+	}
+}
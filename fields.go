@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// bookFields are the JSON keys of Book that ?fields= may select.
+var bookFields = []string{"id", "name", "author", "isbn", "created_at", "updated_at", "deleted_at", "version"}
+
+// parseFields reads a comma-separated fields parameter. A nil set means no
+// projection was asked for; otherwise id is always part of it.
+func parseFields(param string) (map[string]bool, error) {
+	if strings.TrimSpace(param) == "" {
+		return nil, nil
+	}
+
+	fields := map[string]bool{"id": true}
+
+	for _, field := range strings.Split(param, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		if !slices.Contains(bookFields, field) {
+			return nil, fmt.Errorf("unknown field %q, valid fields are: %s",
+				field, strings.Join(bookFields, ", "))
+		}
+
+		fields[field] = true
+	}
+
+	return fields, nil
+}
+
+// projectBook returns the JSON form of book with only the given fields.
+func projectBook(book Book, fields map[string]bool) (map[string]any, error) {
+	data, err := json.Marshal(book)
+	if err != nil {
+		return nil, err
+	}
+
+	var projected map[string]any
+	if err := json.Unmarshal(data, &projected); err != nil {
+		return nil, err
+	}
+
+	for key := range projected {
+		if !fields[key] {
+			delete(projected, key)
+		}
+	}
+
+	return projected, nil
+}
+
+// projectPage applies projectBook to every book of page, keeping the
+// envelope's other keys.
+func projectPage(page BookPage, fields map[string]bool) (map[string]any, error) {
+	data := make([]map[string]any, 0, len(page.Data))
+
+	for _, book := range page.Data {
+		projected, err := projectBook(book, fields)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, projected)
+	}
+
+	return map[string]any{
+		"data":   data,
+		"total":  page.Total,
+		"limit":  page.Limit,
+		"offset": page.Offset,
+	}, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBookFields(t *testing.T) {
+	router := newClockedRouter(t)
+	path := "/v1/book/" + seedID
+
+	for query, want := range map[string]string{
+		"fields=id,name":            `{"id":"` + seedID + `","name":"Emma"}`,
+		"fields=name":               `{"id":"` + seedID + `","name":"Emma"}`,
+		"fields=author,%20version,": `{"author":"Austen","id":"` + seedID + `","version":1}`,
+		"fields=deleted_at":         `{"id":"` + seedID + `"}`,
+	} {
+		w := do(t, router, http.MethodGet, path+"?"+query, "", "Accept", "application/xml")
+		if w.Code != http.StatusOK || w.Body.String() != want || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			t.Errorf("%s: status %d, Content-Type %q, body %s; want %s", query, w.Code, w.Header().Get("Content-Type"), w.Body, want)
+		}
+	}
+
+	if full := decode[Book](t, do(t, router, http.MethodGet, path+"?fields=", "")); full.Name != "Emma" || full.CreatedAt.IsZero() {
+		t.Errorf("empty fields: %+v, want the whole book", full)
+	}
+
+	w := do(t, router, http.MethodGet, path+"?fields=name,title", "")
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `\"title\"`) ||
+		!strings.Contains(w.Body.String(), strings.Join(bookFields, ", ")) {
+		t.Errorf("invalid field: status %d, body %s; want 400 naming it and the valid fields", w.Code, w.Body)
+	}
+}
+
+func TestListFields(t *testing.T) {
+	router := newLibraryRouter(t)
+
+	for path, want := range map[string]string{
+		"/v1/book?fields=name&author=austen": `{"data":[{"id":"33333333-3333-3333-3333-333333333333","name":"Emma"}],"limit":50,"offset":0,"total":1}`,
+		"/v1/book?fields=author&limit=1":     `{"data":[{"author":"Frank Herbert","id":"11111111-1111-1111-1111-111111111111"}],"limit":1,"offset":0,"total":4}`,
+		"/v1/book/search?q=hobbit&fields=id": `{"data":[{"id":"44444444-4444-4444-4444-444444444444"}],"limit":50,"offset":0,"total":1}`,
+	} {
+		w := do(t, router, http.MethodGet, path, "")
+		if w.Code != http.StatusOK || w.Body.String() != want {
+			t.Errorf("%s: status %d, body\n%s\nwant\n%s", path, w.Code, w.Body, want)
+		}
+	}
+
+	for _, path := range []string{"/v1/book?fields=id,title", "/v1/book/search?q=emma&fields=pages"} {
+		if w := do(t, router, http.MethodGet, path, ""); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "valid fields are") {
+			t.Errorf("%s: status %d, body %s; want 400 listing the valid fields", path, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// JSONFileStore keeps books in a MemoryStore and rewrites the whole file
+// after every change, so all of its writes take every shard lock.
+type JSONFileStore struct {
+	*MemoryStore
+	Path string
+}
+
+func NewJSONFileStore(path string) (*JSONFileStore, error) {
+	fs := &JSONFileStore{
+		MemoryStore: NewMemoryStore(),
+		Path:        path,
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var books map[string]Book
+	if err := json.Unmarshal(data, &books); err != nil {
+		return nil, err
+	}
+
+	for _, book := range books {
+		fs.putLocked(book)
+	}
+
+	return fs, nil
+}
+
+func (fs *JSONFileStore) Create(ctx context.Context, book Book) error {
+	fs.lockAll()
+	defer fs.unlockAll()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := fs.createLocked(book); err != nil {
+		return err
+	}
+
+	if err := fs.save(); err != nil {
+		fs.deleteLocked(book.ID)
+		return err
+	}
+
+	return nil
+}
+
+func (fs *JSONFileStore) CreateBatch(ctx context.Context, books []Book) error {
+	fs.lockAll()
+	defer fs.unlockAll()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := fs.createBatchLocked(books); err != nil {
+		return err
+	}
+
+	if err := fs.save(); err != nil {
+		for _, book := range books {
+			fs.deleteLocked(book.ID)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (fs *JSONFileStore) Update(ctx context.Context, id string, book Book) error {
+	fs.lockAll()
+	defer fs.unlockAll()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	old, err := fs.updateLocked(id, book)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.save(); err != nil {
+		fs.putLocked(old)
+		return err
+	}
+
+	return nil
+}
+
+func (fs *JSONFileStore) Delete(ctx context.Context, id string) error {
+	fs.lockAll()
+	defer fs.unlockAll()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	old, err := fs.deleteLocked(id)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.save(); err != nil {
+		fs.putLocked(old)
+		return err
+	}
+
+	return nil
+}
+
+func (fs *JSONFileStore) SoftDeleteBatch(ctx context.Context, ids []string, deletedAt time.Time) ([]string, error) {
+	fs.lockAll()
+	defer fs.unlockAll()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	deleted := fs.softDeleteBatchLocked(ids, deletedAt)
+
+	if err := fs.save(); err != nil {
+		for _, id := range deleted {
+			shard := fs.shard(id)
+			book := shard.books[id]
+			book.DeletedAt = nil
+			book.Version--
+			shard.books[id] = book
+		}
+		return nil, err
+	}
+
+	return deleted, nil
+}
+
+func (fs *JSONFileStore) DeleteAll(ctx context.Context) (int, error) {
+	fs.lockAll()
+	defer fs.unlockAll()
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	removed := fs.deleteAllLocked()
+
+	if err := fs.save(); err != nil {
+		for _, book := range removed {
+			fs.putLocked(book)
+		}
+		return 0, err
+	}
+
+	return len(removed), nil
+}
+
+// save must be called with every shard locked.
+func (fs *JSONFileStore) save() error {
+	books := make(map[string]Book)
+	for _, book := range fs.allLocked() {
+		books[book.ID] = book
+	}
+
+	return writeJSONFile(fs.Path, books)
+}
+
+// writeJSONFile replaces path with v encoded as JSON. Writing to a temp file
+// and renaming it over path keeps the file intact if the process dies
+// mid-write.
+func writeJSONFile(path string, v any) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := json.NewEncoder(tmp).Encode(v); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJSONFileStoreReload(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "books.json")
+
+	store, err := NewJSONFileStore(path)
+	if err != nil {
+		t.Fatalf("opening a missing file: %v", err)
+	}
+	if n, _ := store.Count(ctx); n != 0 {
+		t.Fatalf("missing file loaded %d books", n)
+	}
+
+	want := map[string]string{"a": "Emma", "b": "Persuasion", "c": "Sanditon"}
+	for id, name := range want {
+		if err := store.Create(ctx, Book{ID: id, Name: name, Author: "Austen", Version: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reloaded, err := NewJSONFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	books, err := reloaded.GetAll(ctx)
+	if err != nil || len(books) != len(want) {
+		t.Fatalf("reloaded %d books, %v; want %d", len(books), err, len(want))
+	}
+	for _, book := range books {
+		if want[book.ID] != book.Name {
+			t.Errorf("reloaded %+v", book)
+		}
+	}
+
+	// Every write goes through a temp file that is renamed into place.
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("%d files left in the data directory, want only books.json", len(entries))
+	}
+}
+
+func TestJSONFileStoreRejectsCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "books.json")
+	if err := os.WriteFile(path, []byte(`{"a":`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewJSONFileStore(path); err == nil {
+		t.Error("a truncated file was loaded without an error")
+	}
+}
+
+func TestDataFileSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "books.json")
+
+	serve := func() http.Handler {
+		store, err := openStore("", "", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return setupRouter(newStoreService(t, store))
+	}
+
+	book := postBook(t, serve(), `{"name":"Emma","author":"Austen"}`)
+
+	w := do(t, serve(), http.MethodGet, "/v1/book/"+book.ID, "")
+	if w.Code != http.StatusOK || decode[Book](t, w).Name != "Emma" {
+		t.Errorf("after restart: status %d, body %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"strings"
+
+	"github.com/Qy-wy/CRUD-API.git/bookpb"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcBookServer serves bookpb.BookService from the same store, rules and
+// event hub as the REST handlers.
+type grpcBookServer struct {
+	bookpb.UnimplementedBookServiceServer
+	bs *BookService
+}
+
+// newGRPCServer builds the gRPC server for bs. tlsConfig may be nil for a
+// plaintext server.
+func newGRPCServer(bs *BookService, tlsConfig *tls.Config) *grpc.Server {
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(bs.grpcAuth())}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	srv := grpc.NewServer(opts...)
+	bookpb.RegisterBookServiceServer(srv, &grpcBookServer{bs: bs})
+
+	return srv
+}
+
+// grpcAuth applies the REST auth rules to gRPC calls: API keys in the
+// x-api-key metadata on reads unless AuthMode is write, and on writes; a
+// bearer token in authorization on writes when JWTSecret is set.
+func (bs *BookService) grpcAuth() grpc.UnaryServerInterceptor {
+	var verifyJWT func(string) error
+	if len(bs.JWTSecret) > 0 {
+		verifyJWT = jwtVerifier(bs.JWTSecret)
+	}
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		write := info.FullMethod != bookpb.BookService_GetBook_FullMethodName &&
+			info.FullMethod != bookpb.BookService_ListBooks_FullMethodName
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		first := func(key string) string {
+			if values := md.Get(key); len(values) > 0 {
+				return values[0]
+			}
+			return ""
+		}
+
+		if len(bs.APIKeys) > 0 && (write || bs.AuthMode != authModeWrite) {
+			key := first(strings.ToLower(apiKeyHeader))
+			if key == "" {
+				return nil, status.Error(codes.Unauthenticated, "missing API key")
+			}
+			if !validAPIKey(bs.APIKeys, key) {
+				return nil, status.Error(codes.Unauthenticated, "invalid API key")
+			}
+		}
+
+		if write && verifyJWT != nil {
+			if err := verifyJWT(first("authorization")); err != nil {
+				return nil, status.Error(codes.Unauthenticated, err.Error())
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func (s *grpcBookServer) error(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return status.Error(codes.NotFound, "Record not found")
+	case duplicateMessage(err) != "":
+		return status.Error(codes.AlreadyExists, duplicateMessage(err))
+	case errors.Is(err, ErrVersionConflict):
+		return status.Error(codes.Aborted, "Book was modified concurrently, retry the request")
+	case isContextError(err):
+		return status.FromContextError(err).Err()
+	default:
+		method, _ := grpc.Method(ctx)
+		s.bs.Logger.WithFields(logrus.Fields{
+			"error":  err.Error(),
+			"method": method,
+		}).Error("Error when accessing storage")
+		return status.Error(codes.Internal, "Internal server error")
+	}
+}
+
+func validationStatus(err error) error {
+	fields, _ := validationFields(err)
+	return status.Error(codes.InvalidArgument, "Validation failed: "+strings.Join(fields, "; "))
+}
+
+func toProtoBook(book Book) *bookpb.Book {
+	pb := &bookpb.Book{
+		Id:        book.ID,
+		Name:      book.Name,
+		Author:    book.Author,
+		Isbn:      book.ISBN,
+		CreatedAt: timestamppb.New(book.CreatedAt),
+		UpdatedAt: timestamppb.New(book.UpdatedAt),
+		Version:   int32(book.Version),
+	}
+	if book.deleted() {
+		pb.DeletedAt = timestamppb.New(*book.DeletedAt)
+	}
+
+	return pb
+}
+
+// fromProtoBook takes the client-settable fields; timestamps are always
+// assigned by the server.
+func fromProtoBook(pb *bookpb.Book) Book {
+	return Book{
+		ID:      pb.GetId(),
+		Name:    pb.GetName(),
+		Author:  pb.GetAuthor(),
+		ISBN:    pb.GetIsbn(),
+		Version: int(pb.GetVersion()),
+	}
+}
+
+func (s *grpcBookServer) GetBook(ctx context.Context, req *bookpb.GetBookRequest) (*bookpb.Book, error) {
+	book, err := s.bs.Store.GetByID(ctx, req.GetId())
+	if err == nil && book.deleted() && !req.GetIncludeDeleted() {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return nil, s.error(ctx, err)
+	}
+
+	return toProtoBook(book), nil
+}
+
+func (s *grpcBookServer) ListBooks(ctx context.Context, req *bookpb.ListBooksRequest) (*bookpb.ListBooksResponse, error) {
+	limit, offset := int(req.GetLimit()), int(req.GetOffset())
+	if limit < 0 || offset < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit and offset must not be negative")
+	}
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+
+	books, err := s.bs.Store.GetAll(ctx)
+	if err != nil {
+		return nil, s.error(ctx, err)
+	}
+
+	books = filterBooks(books, BookFilter{Author: req.GetAuthor(), IncludeDeleted: req.GetIncludeDeleted()})
+	sortBooks(books, "")
+
+	resp := &bookpb.ListBooksResponse{Total: int32(len(books))}
+	for _, book := range paginate(books, limit, offset) {
+		resp.Books = append(resp.Books, toProtoBook(book))
+	}
+
+	return resp, nil
+}
+
+func (s *grpcBookServer) CreateBook(ctx context.Context, req *bookpb.CreateBookRequest) (*bookpb.Book, error) {
+	book := fromProtoBook(req.GetBook())
+
+	if err := binding.Validator.ValidateStruct(&book); err != nil {
+		return nil, validationStatus(err)
+	}
+
+	if err := s.bs.prepareNewBook(&book); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.bs.Store.Create(ctx, book); err != nil {
+		return nil, s.error(ctx, err)
+	}
+
+	s.bs.publish(eventCreated, book)
+	return toProtoBook(book), nil
+}
+
+func (s *grpcBookServer) UpdateBook(ctx context.Context, req *bookpb.UpdateBookRequest) (*bookpb.Book, error) {
+	book := fromProtoBook(req.GetBook())
+	if book.ID == "" {
+		return nil, status.Error(codes.InvalidArgument, "book.id is required")
+	}
+
+	if err := binding.Validator.ValidateStruct(&book); err != nil {
+		return nil, validationStatus(err)
+	}
+
+	existing, err := s.bs.getActiveBook(ctx, book.ID)
+	if err != nil {
+		return nil, s.error(ctx, err)
+	}
+
+	if book.Version != 0 && book.Version != existing.Version {
+		return nil, status.Errorf(codes.Aborted,
+			"Book has been modified since the version in the request, current version is %d", existing.Version)
+	}
+
+	if err := s.bs.checkISBN(&book); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	book.CreatedAt = existing.CreatedAt
+	book.UpdatedAt = s.bs.now()
+	book.DeletedAt = nil
+	book.Version = existing.Version + 1
+
+	if err := s.bs.Store.Update(ctx, book.ID, book); err != nil {
+		return nil, s.error(ctx, err)
+	}
+
+	s.bs.publish(eventUpdated, book)
+	return toProtoBook(book), nil
+}
+
+func (s *grpcBookServer) DeleteBook(ctx context.Context, req *bookpb.DeleteBookRequest) (*emptypb.Empty, error) {
+	if err := s.bs.softDelete(ctx, req.GetId()); err != nil {
+		return nil, s.error(ctx, err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// stopGRPC drains srv like http.Server.Shutdown, cutting remaining calls
+// off once ctx is done.
+func stopGRPC(ctx context.Context, srv *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		srv.Stop()
+	}
+}
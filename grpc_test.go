@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/Qy-wy/CRUD-API.git/bookpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// grpcClient serves bs over an in-memory listener and returns a client
+// connected to it.
+func grpcClient(t *testing.T, bs *BookService) bookpb.BookServiceClient {
+	t.Helper()
+
+	ln := bufconn.Listen(1 << 20)
+	srv := newGRPCServer(bs, nil)
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return bookpb.NewBookServiceClient(conn)
+}
+
+func TestGRPCCreateThenGet(t *testing.T) {
+	bs := newTestService(t)
+	client := grpcClient(t, bs)
+	ctx := context.Background()
+
+	created, err := client.CreateBook(ctx, &bookpb.CreateBookRequest{Book: &bookpb.Book{Name: "Emma", Author: "Austen"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.GetId() == "" || created.GetVersion() != 1 || created.GetCreatedAt() == nil {
+		t.Errorf("created %v", created)
+	}
+
+	got, err := client.GetBook(ctx, &bookpb.GetBookRequest{Id: created.GetId()})
+	if err != nil || got.GetName() != "Emma" || got.GetAuthor() != "Austen" {
+		t.Errorf("GetBook = %v, %v", got, err)
+	}
+
+	// REST and gRPC share the store.
+	if book := decode[Book](t, do(t, setupRouter(bs), http.MethodGet, "/v1/book/"+created.GetId(), "")); book.Name != "Emma" {
+		t.Errorf("REST sees %+v", book)
+	}
+
+	for name, call := range map[string]func() error{
+		"get missing": func() error {
+			_, err := client.GetBook(ctx, &bookpb.GetBookRequest{Id: seedID})
+			return wantCode(err, codes.NotFound)
+		},
+		"duplicate create": func() error {
+			_, err := client.CreateBook(ctx, &bookpb.CreateBookRequest{Book: &bookpb.Book{Id: created.GetId(), Name: "Emma", Author: "Austen"}})
+			return wantCode(err, codes.AlreadyExists)
+		},
+		"invalid create": func() error {
+			_, err := client.CreateBook(ctx, &bookpb.CreateBookRequest{Book: &bookpb.Book{Name: "Emma"}})
+			return wantCode(err, codes.InvalidArgument)
+		},
+	} {
+		if err := call(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestGRPCAuth(t *testing.T) {
+	bs := newTestService(t)
+	bs.APIKeys = []string{"secret"}
+	client := grpcClient(t, bs)
+
+	_, err := client.ListBooks(context.Background(), &bookpb.ListBooksRequest{})
+	if err := wantCode(err, codes.Unauthenticated); err != nil {
+		t.Errorf("without a key: %v", err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "secret")
+	if resp, err := client.ListBooks(ctx, &bookpb.ListBooksRequest{}); err != nil || resp.GetTotal() != 0 {
+		t.Errorf("with a key: %v, %v", resp, err)
+	}
+}
+
+// wantCode returns nil when err is a gRPC status with code.
+func wantCode(err error, code codes.Code) error {
+	if status.Code(err) == code {
+		return nil
+	}
+	return fmt.Errorf("got %v, want %s", err, code)
+}
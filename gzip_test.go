@@ -0,0 +1,115 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	for header, want := range map[string]bool{
+		"":                     false,
+		"gzip":                 true,
+		"deflate, gzip;q=0.5":  true,
+		"gzip;q=0":             false,
+		"*":                    true,
+		"br, identity":         false,
+		" GZIP ":               false,
+		"gzip; q=0.000, *;q=1": false,
+	} {
+		if got := acceptsGzip(header); got != want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestGzipLargeList(t *testing.T) {
+	bs := newTestService(t)
+	bs.GzipMinSize = defaultGzipMinSize
+	books := seedBooks(t, bs, 50)
+	router := setupRouter(bs)
+
+	plain := do(t, router, http.MethodGet, "/v1/book?limit=50", "")
+	if plain.Header().Get("Content-Encoding") != "" || plain.Body.Len() < defaultGzipMinSize {
+		t.Fatalf("without Accept-Encoding: Content-Encoding %q, %d bytes", plain.Header().Get("Content-Encoding"), plain.Body.Len())
+	}
+
+	w := do(t, router, http.MethodGet, "/v1/book?limit=50", "", "Accept-Encoding", "gzip")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("status %d, Content-Encoding %q", w.Code, w.Header().Get("Content-Encoding"))
+	}
+	if vary := w.Header().Get("Vary"); !strings.Contains(vary, "Accept-Encoding") {
+		t.Errorf("Vary %q", vary)
+	}
+
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != plain.Body.String() {
+		t.Errorf("decompressed body\n%s\nwant\n%s", body, plain.Body)
+	}
+
+	small := do(t, router, http.MethodGet, "/v1/book/"+books[0].ID, "", "Accept-Encoding", "gzip")
+	if small.Header().Get("Content-Encoding") != "" || !strings.Contains(small.Body.String(), `"name":"Book 0"`) {
+		t.Errorf("single book: Content-Encoding %q, body %s", small.Header().Get("Content-Encoding"), small.Body)
+	}
+	if vary := small.Header().Get("Vary"); !strings.Contains(vary, "Accept-Encoding") {
+		t.Errorf("single book: Vary %q", vary)
+	}
+}
+
+func TestGzipMinSize(t *testing.T) {
+	bs := newTestService(t)
+	bs.GzipMinSize = 1 << 20
+	seedBooks(t, bs, 50)
+
+	w := do(t, setupRouter(bs), http.MethodGet, "/v1/book?limit=50", "", "Accept-Encoding", "gzip")
+	if w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("response under the threshold was compressed")
+	}
+
+	bs.GzipMinSize = 0
+	w = do(t, setupRouter(bs), http.MethodGet, "/v1/book?limit=50", "", "Accept-Encoding", "gzip")
+	if w.Header().Get("Content-Encoding") != "" || strings.Contains(w.Header().Get("Vary"), "Accept-Encoding") {
+		t.Errorf("0 disables compression: Content-Encoding %q, Vary %q", w.Header().Get("Content-Encoding"), w.Header().Get("Vary"))
+	}
+}
+
+func TestGzipPanicStillAnswers500(t *testing.T) {
+	for _, minSize := range []int{1, defaultGzipMinSize} {
+		bs := newTestService(t)
+		bs.GzipMinSize = minSize
+		router := setupRouter(bs)
+		router.GET("/v1/panic", func(c *gin.Context) { panic("boom") })
+
+		w := do(t, router, http.MethodGet, "/v1/panic", "", "Accept-Encoding", "gzip")
+
+		body := w.Body.Bytes()
+		if w.Header().Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatalf("min size %d: %v", minSize, err)
+			}
+			if body, err = io.ReadAll(gz); err != nil {
+				t.Fatalf("min size %d: %v", minSize, err)
+			}
+		}
+
+		var envelope struct {
+			Error APIError `json:"error"`
+		}
+		if err := json.Unmarshal(body, &envelope); err != nil || w.Code != http.StatusInternalServerError || envelope.Error.Code != CodeInternal {
+			t.Errorf("min size %d: status %d, body %q, %v; want the 500 envelope", minSize, w.Code, body, err)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// healthz is the liveness probe: it only reports that the process is
+// serving requests and never touches storage.
+func healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// readyz is the readiness probe: it fails with 503 until startup has
+// finished, once shutdown has begun and while the storage backend can't
+// serve requests.
+func (bs *BookService) readyz(c *gin.Context) {
+	if !bs.ready.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable"})
+		return
+	}
+
+	if err := bs.Store.Ready(c.Request.Context()); err != nil {
+		bs.logError(err, c, "Storage readiness check failed")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
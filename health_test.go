@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestHealthz(t *testing.T) {
+	bs := newTestService(t)
+	bs.APIKeys = []string{"secret"}
+	router := setupRouter(bs)
+
+	w := do(t, router, http.MethodGet, "/healthz", "")
+	if w.Code != http.StatusOK || w.Body.String() != `{"status":"ok"}` {
+		t.Errorf("status %d, body %s", w.Code, w.Body)
+	}
+}
+
+// downStore is a backend that can't be reached: every call fails.
+type downStore struct {
+	BookStore
+}
+
+func (downStore) Ready(ctx context.Context) error {
+	return errTestStore
+}
+
+func TestHealthzDoesNotTouchStorage(t *testing.T) {
+	bs := newStoreService(t, downStore{})
+	bs.JWTSecret = testJWTSecret
+	bs.APIKeys = []string{"secret"}
+	router := setupRouter(bs)
+
+	w := do(t, router, http.MethodGet, "/healthz", "")
+	if w.Code != http.StatusOK || w.Body.String() != `{"status":"ok"}` {
+		t.Errorf("status %d, body %s", w.Code, w.Body)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	for name, tt := range map[string]struct {
+		store      BookStore
+		ready      bool
+		wantStatus int
+	}{
+		"healthy store":   {NewMemoryStore(), true, http.StatusOK},
+		"store down":      {downStore{}, true, http.StatusServiceUnavailable},
+		"not started yet": {NewMemoryStore(), false, http.StatusServiceUnavailable},
+	} {
+		bs := newStoreService(t, tt.store)
+		bs.ready.Store(tt.ready)
+
+		w := do(t, setupRouter(bs), http.MethodGet, "/readyz", "")
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status %d, body %s; want %d", name, w.Code, w.Body, tt.wantStatus)
+		}
+	}
+}
+
+func TestReadinessLifecycle(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	bs := newBookService(NewMemoryStore(), logger)
+	router := setupRouter(bs)
+
+	for _, step := range []struct {
+		name       string
+		ready      bool
+		wantStatus int
+	}{
+		{"before init", false, http.StatusServiceUnavailable},
+		{"after init", true, http.StatusOK},
+		{"during shutdown", false, http.StatusServiceUnavailable},
+	} {
+		bs.ready.Store(step.ready)
+
+		if w := do(t, router, http.MethodGet, "/readyz", ""); w.Code != step.wantStatus {
+			t.Errorf("%s: /readyz status %d, want %d", step.name, w.Code, step.wantStatus)
+		}
+		if w := do(t, router, http.MethodGet, "/healthz", ""); w.Code != http.StatusOK {
+			t.Errorf("%s: /healthz status %d, want liveness unaffected", step.name, w.Code)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyKeyHeader       = "Idempotency-Key"
+	defaultIdempotencyTTL      = 24 * time.Hour
+	defaultIdempotencyMaxKeys  = 10000
+	idempotencyCleanupInterval = time.Minute
+)
+
+// idempotentHeaders are the response headers replayed along with the body.
+var idempotentHeaders = []string{"Content-Type", "Location"}
+
+type idempotentResponse struct {
+	// bodyHash is the SHA-256 of the request body the key was first used
+	// with.
+	bodyHash [sha256.Size]byte
+	done     bool
+	status   int
+	header   http.Header
+	body     []byte
+	expires  time.Time
+}
+
+// IdempotencyCache remembers the response to each Idempotency-Key for ttl so
+// a retried request gets the original answer instead of running again. Keys
+// are scoped to the caller's credentials, so one client can't replay another
+// client's response by guessing its key. At most maxKeys are kept; beyond
+// that the key closest to expiry is dropped.
+type IdempotencyCache struct {
+	mu        sync.Mutex
+	responses map[string]*idempotentResponse
+	ttl       time.Duration
+	maxKeys   int
+}
+
+func NewIdempotencyCache(ttl time.Duration, maxKeys int) *IdempotencyCache {
+	return &IdempotencyCache{
+		responses: make(map[string]*idempotentResponse),
+		ttl:       ttl,
+		maxKeys:   maxKeys,
+	}
+}
+
+// begin returns the stored response for key, or reserves key for the caller
+// when there is none. A reserved key that hasn't finished is returned with
+// done unset.
+func (ic *IdempotencyCache) begin(key string, bodyHash [sha256.Size]byte, now time.Time) (resp *idempotentResponse, reserved bool) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	if resp, exist := ic.responses[key]; exist && now.Before(resp.expires) {
+		return resp, false
+	}
+
+	if ic.maxKeys > 0 && len(ic.responses) >= ic.maxKeys {
+		ic.evictLocked(now)
+	}
+
+	ic.responses[key] = &idempotentResponse{bodyHash: bodyHash, expires: now.Add(ic.ttl)}
+
+	return nil, true
+}
+
+// evictLocked drops expired keys, or the one expiring soonest if none are.
+func (ic *IdempotencyCache) evictLocked(now time.Time) {
+	var oldest string
+
+	for key, resp := range ic.responses {
+		if !now.Before(resp.expires) {
+			delete(ic.responses, key)
+			continue
+		}
+		if oldest == "" || resp.expires.Before(ic.responses[oldest].expires) {
+			oldest = key
+		}
+	}
+
+	if len(ic.responses) >= ic.maxKeys {
+		delete(ic.responses, oldest)
+	}
+}
+
+func (ic *IdempotencyCache) finish(key string, resp *idempotentResponse) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	ic.responses[key] = resp
+}
+
+func (ic *IdempotencyCache) release(key string) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	delete(ic.responses, key)
+}
+
+func (ic *IdempotencyCache) cleanup(now time.Time) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	for key, resp := range ic.responses {
+		if !now.Before(resp.expires) {
+			delete(ic.responses, key)
+		}
+	}
+}
+
+// cleanupLoop drops expired responses until ctx is done.
+func (ic *IdempotencyCache) cleanupLoop(ctx context.Context) {
+	ticker := time.NewTicker(idempotencyCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			ic.cleanup(now)
+		}
+	}
+}
+
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// idempotencyScope returns the cache key for an Idempotency-Key sent by c's
+// caller, identified by the credentials it presented. Only a hash is kept so
+// the cache holds no secrets.
+func idempotencyScope(c *gin.Context, key string) string {
+	h := sha256.New()
+	for _, part := range []string{c.GetHeader(apiKeyHeader), c.GetHeader("Authorization"), key} {
+		io.WriteString(h, part)
+		h.Write([]byte{0})
+	}
+
+	return string(h.Sum(nil))
+}
+
+// Middleware replays the stored response for a repeated Idempotency-Key.
+// Reusing a key with a different body is rejected with 422. Server errors
+// are not stored, so the client can retry them.
+func (ic *IdempotencyCache) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				respondBodyTooLarge(c, tooLarge.Limit)
+				return
+			}
+			respondError(c, http.StatusBadRequest, CodeBadRequest, "Error when reading the request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		key = idempotencyScope(c, key)
+		bodyHash := sha256.Sum256(body)
+		now := time.Now()
+
+		stored, reserved := ic.begin(key, bodyHash, now)
+		if !reserved {
+			if stored.bodyHash != bodyHash {
+				respondError(c, http.StatusUnprocessableEntity, CodeIdempotencyReused,
+					"This Idempotency-Key was already used with a different request body")
+				return
+			}
+			if !stored.done {
+				respondError(c, http.StatusConflict, CodeConflict,
+					"A request with this Idempotency-Key is still being processed")
+				return
+			}
+
+			for name, values := range stored.header {
+				c.Writer.Header()[name] = values
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(stored.status, stored.header.Get("Content-Type"), stored.body)
+			c.Abort()
+			return
+		}
+
+		finished := false
+		defer func() {
+			if !finished {
+				ic.release(key)
+			}
+		}()
+
+		w := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+
+		c.Next()
+
+		c.Writer = w.ResponseWriter
+
+		if w.Status() >= http.StatusInternalServerError {
+			return
+		}
+
+		header := make(http.Header)
+		for _, name := range idempotentHeaders {
+			if value := w.Header().Get(name); value != "" {
+				header.Set(name, value)
+			}
+		}
+
+		ic.finish(key, &idempotentResponse{
+			bodyHash: bodyHash,
+			done:     true,
+			status:   w.Status(),
+			header:   header,
+			body:     w.body.Bytes(),
+			expires:  now.Add(ic.ttl),
+		})
+		finished = true
+	}
+}
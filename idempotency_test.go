@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	bs := newTestService(t)
+	bs.APIKeys = []string{"alice-key", "bob-key"}
+	bs.Idempotency = NewIdempotencyCache(time.Hour, 0)
+	router := setupRouter(bs)
+
+	const body = `{"name":"Emma","author":"Austen"}`
+	post := func(apiKey, body string) (int, string, string) {
+		w := do(t, router, http.MethodPost, "/v1/book", body,
+			apiKeyHeader, apiKey, idempotencyKeyHeader, "k-1")
+		return w.Code, w.Body.String(), w.Header().Get("Idempotent-Replayed")
+	}
+
+	status, first, _ := post("alice-key", body)
+	if status != http.StatusCreated {
+		t.Fatalf("first POST: status %d, body %s", status, first)
+	}
+
+	status, again, replayed := post("alice-key", body)
+	if status != http.StatusCreated || again != first || replayed != "true" {
+		t.Errorf("retry: status %d, replayed %q, body %s; want the first response replayed", status, replayed, again)
+	}
+
+	status, other, replayed := post("bob-key", body)
+	if status != http.StatusCreated || other == first || replayed != "" {
+		t.Errorf("another caller with the same key: status %d, replayed %q; want a new book", status, replayed)
+	}
+
+	w := do(t, router, http.MethodPost, "/v1/book", `{"name":"Persuasion","author":"Austen"}`,
+		apiKeyHeader, "alice-key", idempotencyKeyHeader, "k-1")
+	if w.Code != http.StatusUnprocessableEntity || errorCode(t, w) != CodeIdempotencyReused {
+		t.Errorf("same key, different body: status %d, body %s; want 422", w.Code, w.Body)
+	}
+
+	if n, _ := bs.Store.Count(context.Background()); n != 2 {
+		t.Errorf("%d books stored, want 2", n)
+	}
+}
+
+func TestIdempotencyKeyNotStoredOnServerError(t *testing.T) {
+	bs := newTestService(t)
+	bs.Store = failingStore{bs.Store}
+	bs.Idempotency = NewIdempotencyCache(time.Hour, 0)
+	router := setupRouter(bs)
+
+	for range 2 {
+		w := do(t, router, http.MethodPost, "/v1/book", `{"name":"Emma","author":"Austen"}`,
+			idempotencyKeyHeader, "k-1")
+		if w.Code != http.StatusInternalServerError || w.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("status %d, replayed %q; want a fresh 500", w.Code, w.Header().Get("Idempotent-Replayed"))
+		}
+	}
+}
+
+func TestIdempotencyKeyReplaysCreate(t *testing.T) {
+	bs := newTestService(t)
+	bs.Idempotency = NewIdempotencyCache(defaultIdempotencyTTL, defaultIdempotencyMaxKeys)
+	router := setupRouter(bs)
+
+	post := func() *httptest.ResponseRecorder {
+		return do(t, router, http.MethodPost, "/v1/book", `{"name":"Emma","author":"Austen"}`, idempotencyKeyHeader, "retry-1")
+	}
+
+	first, again := post(), post()
+	if first.Code != http.StatusCreated || again.Code != first.Code || again.Body.String() != first.Body.String() ||
+		again.Header().Get("Location") != first.Header().Get("Location") {
+		t.Errorf("retry: status %d, Location %q, body %s; want %d, %q, %s",
+			again.Code, again.Header().Get("Location"), again.Body, first.Code, first.Header().Get("Location"), first.Body)
+	}
+	if n, _ := bs.Store.Count(context.Background()); n != 1 {
+		t.Errorf("%d books stored, want 1", n)
+	}
+
+	if w := do(t, router, http.MethodPost, "/v1/book", `{"name":"Emma","author":"Austen"}`); w.Code != http.StatusCreated || w.Body.String() == first.Body.String() {
+		t.Errorf("POST without a key: status %d, want a second book", w.Code)
+	}
+}
+
+func TestIdempotencyCacheExpiry(t *testing.T) {
+	ic := NewIdempotencyCache(time.Hour, 2)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var hash [sha256.Size]byte
+
+	for i, key := range []string{"a", "b", "c"} {
+		if _, reserved := ic.begin(key, hash, now.Add(time.Duration(i)*time.Minute)); !reserved {
+			t.Fatalf("%s not reserved", key)
+		}
+	}
+	if _, kept := ic.responses["a"]; kept || len(ic.responses) != 2 {
+		t.Errorf("keys %v, want the oldest dropped to stay within 2", ic.responses)
+	}
+
+	if _, reserved := ic.begin("b", hash, now.Add(30*time.Minute)); reserved {
+		t.Error("b reserved again before its TTL")
+	}
+	if _, reserved := ic.begin("b", hash, now.Add(61*time.Minute)); !reserved {
+		t.Error("b not reusable after its TTL")
+	}
+
+	ic.cleanup(now.Add(63 * time.Minute))
+	if _, kept := ic.responses["c"]; kept {
+		t.Error("cleanup kept the expired key c")
+	}
+	if _, kept := ic.responses["b"]; !kept {
+		t.Error("cleanup dropped b, which was reserved again")
+	}
+}
+
+var errTestStore = errors.New("storage unavailable")
+
+// failingStore fails every create.
+type failingStore struct {
+	BookStore
+}
+
+func (failingStore) Create(ctx context.Context, book Book) error {
+	return errTestStore
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Conflict policies for importBooks, chosen with ?conflict=. They decide
+// what happens to a book whose ID is already taken.
+const (
+	conflictSkip      = "skip"
+	conflictOverwrite = "overwrite"
+	conflictFail      = "fail"
+)
+
+// ImportRow reports a record that was not inserted. Row counts records from
+// 1: array elements for JSON, data rows after the header for CSV.
+type ImportRow struct {
+	Row   int    `json:"row"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error"`
+}
+
+type ImportResult struct {
+	Imported int         `json:"imported"`
+	Skipped  []ImportRow `json:"skipped"`
+	Errors   []ImportRow `json:"errors"`
+	// Overwritten counts books replaced under ?conflict=overwrite. The other
+	// counts are the lengths of Skipped and Errors.
+	Overwritten  int `json:"overwritten"`
+	SkippedCount int `json:"skipped_count"`
+	ErrorCount   int `json:"error_count"`
+}
+
+func (r *ImportResult) skip(row ImportRow) {
+	r.Skipped = append(r.Skipped, row)
+	r.SkippedCount++
+}
+
+func (r *ImportResult) fail(row ImportRow) {
+	r.Errors = append(r.Errors, row)
+	r.ErrorCount++
+}
+
+// importRecord is a parsed book with the row it came from.
+type importRecord struct {
+	row  int
+	book Book
+}
+
+// importSource returns the file to import: the "file" part of a multipart
+// form, or the raw request body otherwise.
+func importSource(c *gin.Context) (io.ReadCloser, error) {
+	if c.ContentType() != binding.MIMEMultipartPOSTForm {
+		return c.Request.Body, nil
+	}
+
+	header, err := c.FormFile("file")
+	if errors.Is(err, http.ErrMissingFile) {
+		return nil, errors.New("multipart form has no file part")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return header.Open()
+}
+
+// readImport parses src as a JSON array when its first non-blank byte is
+// '[' and as CSV otherwise. Records that can't be parsed are returned as
+// failed rows; err is only set when the file as a whole is unusable.
+func readImport(src io.Reader) (records []importRecord, failed []ImportRow, err error) {
+	br := bufio.NewReader(src)
+
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			break
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			br.UnreadByte()
+			if b == '[' {
+				return readImportJSON(br)
+			}
+			break
+		}
+	}
+
+	return readImportCSV(br)
+}
+
+func readImportJSON(src io.Reader) ([]importRecord, []ImportRow, error) {
+	var items []json.RawMessage
+	dec := json.NewDecoder(src)
+	if err := dec.Decode(&items); err != nil {
+		return nil, nil, fmt.Errorf("Invalid JSON: %w", err)
+	}
+	if err := expectEOF(dec); err != nil {
+		return nil, nil, fmt.Errorf("Invalid JSON: %w", err)
+	}
+
+	var (
+		records []importRecord
+		failed  []ImportRow
+	)
+
+	for i, item := range items {
+		row := i + 1
+
+		var book Book
+		if err := strictDecode(bytes.NewReader(item), &book); err != nil {
+			var unknownField *unknownFieldError
+			if errors.As(err, &unknownField) {
+				err = fmt.Errorf("%s is not a known field", unknownField.Field)
+			}
+			failed = append(failed, ImportRow{Row: row, Error: "Invalid JSON: " + err.Error()})
+			continue
+		}
+
+		records = append(records, importRecord{row: row, book: book})
+	}
+
+	return records, failed, nil
+}
+
+func readImportCSV(src io.Reader) ([]importRecord, []ImportRow, error) {
+	r := csv.NewReader(src)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = errors.New("CSV must start with a header row")
+		}
+		return nil, nil, fmt.Errorf("Invalid CSV: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, required := range []string{"name", "author"} {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, errors.New("CSV header must include a " + required + " column")
+		}
+	}
+
+	var (
+		records []importRecord
+		failed  []ImportRow
+	)
+
+	for row := 1; ; row++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			failed = append(failed, ImportRow{Row: row, Error: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("Invalid CSV: %w", err)
+		}
+
+		if len(record) != len(header) {
+			failed = append(failed, ImportRow{
+				Row:   row,
+				Error: "row has a different number of fields than the header",
+			})
+			continue
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		records = append(records, importRecord{row: row, book: Book{
+			ID:     field("id"),
+			Name:   field("name"),
+			Author: field("author"),
+			ISBN:   field("isbn"),
+		}})
+	}
+
+	return records, failed, nil
+}
+
+// importClaims tracks which IDs, ISBNs and, when the store enforces it,
+// names are taken: first by the books already stored, then by each book the
+// import queues. Checking records against it lets importBooks settle every
+// conflict before writing anything and create the new books with a single
+// CreateBatch.
+type importClaims struct {
+	stored map[string]bool
+	isbns  map[string]string
+	// names is nil unless book names must be unique.
+	names map[string]string
+}
+
+func newImportClaims(books []Book, uniqueNames bool) *importClaims {
+	claims := &importClaims{
+		stored: make(map[string]bool, len(books)),
+		isbns:  make(map[string]string, len(books)),
+	}
+	if uniqueNames {
+		claims.names = make(map[string]string, len(books))
+	}
+
+	for _, book := range books {
+		claims.stored[book.ID] = true
+		claims.claim(book)
+	}
+
+	return claims
+}
+
+// check returns the error the store would reject book with because a
+// different book has its ISBN or name.
+func (ic *importClaims) check(book Book) error {
+	if owner, taken := ic.isbns[book.ISBN]; book.ISBN != "" && taken && owner != book.ID {
+		return ErrDuplicateISBN
+	}
+	if owner, taken := ic.names[nameKey(book.Name)]; taken && owner != book.ID {
+		return ErrDuplicateName
+	}
+
+	return nil
+}
+
+// claim marks book's ISBN and name as taken by it. Whatever a book held
+// before an overwrite stays claimed too, since the new books are created
+// while the stored ones still have their old values.
+func (ic *importClaims) claim(book Book) {
+	if book.ISBN != "" {
+		ic.isbns[book.ISBN] = book.ID
+	}
+	if ic.names != nil {
+		ic.names[nameKey(book.Name)] = book.ID
+	}
+}
+
+// importBooks creates a book per record of an uploaded JSON array or CSV
+// file. Invalid records are reported rather than aborting the import; what
+// happens to a book whose ID is taken depends on ?conflict=: skip (the
+// default) leaves the existing book alone, overwrite replaces it and fail
+// rejects the whole import with nothing written.
+func (bs *BookService) importBooks(c *gin.Context) {
+	policy := c.DefaultQuery("conflict", conflictSkip)
+	if policy != conflictSkip && policy != conflictOverwrite && policy != conflictFail {
+		respondError(c, http.StatusBadRequest, CodeBadRequest,
+			"conflict must be one of skip, overwrite, fail")
+		return
+	}
+
+	src, err := importSource(c)
+	if err != nil {
+		bs.importError(err, c)
+		return
+	}
+	defer src.Close()
+
+	records, failed, err := readImport(src)
+	if err != nil {
+		bs.importError(err, c)
+		return
+	}
+
+	stored, err := bs.Store.GetAll(c.Request.Context())
+	if err != nil {
+		bs.storeError(err, c)
+		return
+	}
+	_, uniqueNames := bs.Store.(*uniqueNameStore)
+	claims := newImportClaims(stored, uniqueNames)
+
+	result := ImportResult{Skipped: []ImportRow{}, Errors: []ImportRow{}}
+	for _, row := range failed {
+		result.fail(row)
+	}
+
+	var (
+		queued []importRecord
+		index  = make(map[string]int)
+	)
+
+	for _, record := range records {
+		book, row := record.book, record.row
+
+		if err := binding.Validator.ValidateStruct(&book); err != nil {
+			fields, _ := validationFields(err)
+			result.fail(ImportRow{
+				Row:   row,
+				ID:    book.ID,
+				Error: "Validation failed: " + strings.Join(fields, "; "),
+			})
+			continue
+		}
+
+		if err := bs.prepareNewBook(&book); err != nil {
+			result.fail(ImportRow{Row: row, ID: book.ID, Error: err.Error()})
+			continue
+		}
+
+		i, inFile := index[book.ID]
+		if inFile || claims.stored[book.ID] {
+			clash := ImportRow{Row: row, ID: book.ID, Error: duplicateMessage(ErrConflict)}
+			if inFile {
+				clash.Error = "ID appears earlier in the file"
+			}
+
+			switch policy {
+			case conflictFail:
+				importConflict(c, clash)
+				return
+			case conflictSkip:
+				result.skip(clash)
+				continue
+			}
+		}
+
+		if err := claims.check(book); err != nil {
+			clash := ImportRow{Row: row, ID: book.ID, Error: duplicateMessage(err)}
+
+			switch policy {
+			case conflictFail:
+				importConflict(c, clash)
+				return
+			case conflictOverwrite:
+				// Only the ID can be overwritten; an ISBN or name clash with
+				// a different book is a failure.
+				result.fail(clash)
+			default:
+				result.skip(clash)
+			}
+			continue
+		}
+		claims.claim(book)
+
+		if inFile {
+			queued[i] = importRecord{row: row, book: book}
+			result.Overwritten++
+			continue
+		}
+		index[book.ID] = len(queued)
+		queued = append(queued, importRecord{row: row, book: book})
+	}
+
+	var (
+		books      []Book
+		rows       []int
+		overwrites []importRecord
+	)
+
+	for _, record := range queued {
+		if claims.stored[record.book.ID] {
+			overwrites = append(overwrites, record)
+			continue
+		}
+		books = append(books, record.book)
+		rows = append(rows, record.row)
+	}
+
+	if len(books) > 0 {
+		// A book another request wrote since GetAll can still clash. The
+		// batch is all-or-nothing and runs before any overwrite, so the
+		// import is then rejected with nothing written.
+		if err := bs.Store.CreateBatch(c.Request.Context(), books); err != nil {
+			var batchErr *BatchError
+			if message := duplicateMessage(err); message != "" && errors.As(err, &batchErr) {
+				i := batchErr.Index
+				importConflict(c, ImportRow{Row: rows[i], ID: books[i].ID, Error: message})
+				return
+			}
+
+			bs.storeError(err, c)
+			return
+		}
+	}
+
+	result.Imported = len(books)
+	bs.publish(eventCreated, books...)
+
+	for _, record := range overwrites {
+		book, err := bs.overwriteBook(c.Request.Context(), record.book)
+		if err == nil {
+			result.Overwritten++
+			bs.publish(eventUpdated, book)
+			continue
+		}
+
+		clash := ImportRow{Row: record.row, ID: record.book.ID, Error: duplicateMessage(err)}
+		if clash.Error == "" {
+			if !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrVersionConflict) {
+				bs.storeError(err, c)
+				return
+			}
+			clash.Error = "Book was modified during the import"
+		}
+		result.fail(clash)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// overwriteBook replaces the stored book with the same ID by book, keeping
+// its creation time and continuing its version. A soft-deleted book is
+// restored.
+func (bs *BookService) overwriteBook(ctx context.Context, book Book) (Book, error) {
+	existing, err := bs.Store.GetByID(ctx, book.ID)
+	if err != nil {
+		return Book{}, err
+	}
+
+	book.CreatedAt = existing.CreatedAt
+	book.UpdatedAt = bs.now()
+	book.DeletedAt = nil
+	book.Version = existing.Version + 1
+
+	return book, bs.Store.Update(ctx, book.ID, book)
+}
+
+func importConflict(c *gin.Context, row ImportRow) {
+	respondErrorDetails(c, http.StatusConflict, CodeConflict,
+		"Import rejected: a book conflicts with another book", []ImportRow{row})
+}
+
+func (bs *BookService) importError(err error, c *gin.Context) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respondBodyTooLarge(c, tooLarge.Limit)
+		return
+	}
+
+	respondError(c, http.StatusBadRequest, CodeBadRequest, err.Error())
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestImportCSV(t *testing.T) {
+	router := newSeededRouter(t)
+
+	csv := "id,name,author\n" +
+		"33333333-3333-3333-3333-333333333333,Dune,Herbert\n" +
+		seedID + ",Not Emma,Someone\n" +
+		"44444444-4444-4444-4444-444444444444,Broken\n" +
+		",Persuasion,Austen\n"
+	w := do(t, router, http.MethodPost, "/v1/book/import", csv, "Content-Type", "text/csv")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", w.Code, w.Body)
+	}
+
+	result := decode[ImportResult](t, w)
+	if result.Imported != 2 || result.SkippedCount != 1 || result.ErrorCount != 1 || result.Overwritten != 0 {
+		t.Errorf("summary %s, want 2 imported, 1 skipped, 1 error", w.Body)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].Row != 2 || result.Skipped[0].ID != seedID {
+		t.Errorf("skipped %+v, want row 2 with the duplicate ID", result.Skipped)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Row != 3 || result.Errors[0].Error == "" {
+		t.Errorf("errors %+v, want row 3 with a reason", result.Errors)
+	}
+
+	if got := decode[Book](t, do(t, router, http.MethodGet, "/v1/book/"+seedID, "")); got.Name != "Emma" || got.Version != 1 {
+		t.Errorf("duplicate row overwrote the stored book: %+v", got)
+	}
+	if page := decode[BookPage](t, do(t, router, http.MethodGet, "/v1/book?sort=name", "")); page.Total != 3 ||
+		page.Data[0].Name != "Dune" || page.Data[2].Name != "Persuasion" || page.Data[2].ID == "" {
+		t.Errorf("stored after import: %+v", page.Data)
+	}
+
+	for body, want := range map[string]string{
+		"":                 "header row",
+		"id,title\n1,Dune": "name column",
+	} {
+		w := do(t, router, http.MethodPost, "/v1/book/import", body, "Content-Type", "text/csv")
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), want) {
+			t.Errorf("import of %q: status %d, body %s; want 400 about the %s", body, w.Code, w.Body, want)
+		}
+	}
+}
+
+// importFile uploads content as the "file" part of a multipart form to
+// /v1/book/import with query.
+func importFile(t *testing.T, h http.Handler, query, content string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "books")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(part, content)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/book/import"+query, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestImportConflictPolicies(t *testing.T) {
+	const csv = "id,name,author\n" +
+		seedID + ",Emma,Jane Austen\n" +
+		"33333333-3333-3333-3333-333333333333,Dune,Herbert\n"
+
+	for _, tt := range []struct {
+		query      string
+		wantStatus int
+		wantAuthor string
+		wantTotal  int
+		want       ImportResult
+	}{
+		{"", http.StatusOK, "Austen", 2, ImportResult{Imported: 1, SkippedCount: 1}},
+		{"?conflict=skip", http.StatusOK, "Austen", 2, ImportResult{Imported: 1, SkippedCount: 1}},
+		{"?conflict=overwrite", http.StatusOK, "Jane Austen", 2, ImportResult{Imported: 1, Overwritten: 1}},
+		{"?conflict=fail", http.StatusConflict, "Austen", 1, ImportResult{}},
+	} {
+		router := newSeededRouter(t)
+
+		w := importFile(t, router, tt.query, csv)
+		if w.Code != tt.wantStatus {
+			t.Errorf("%q: status %d, body %s", tt.query, w.Code, w.Body)
+			continue
+		}
+		if w.Code == http.StatusOK {
+			got := decode[ImportResult](t, w)
+			if got.Imported != tt.want.Imported || got.SkippedCount != tt.want.SkippedCount || got.Overwritten != tt.want.Overwritten || got.ErrorCount != 0 {
+				t.Errorf("%q: summary %s", tt.query, w.Body)
+			}
+			if tt.want.SkippedCount == 1 && (len(got.Skipped) != 1 || got.Skipped[0].ID != seedID) {
+				t.Errorf("%q: skipped %+v, want the pre-existing ID", tt.query, got.Skipped)
+			}
+		}
+
+		if got := decode[Book](t, do(t, router, http.MethodGet, "/v1/book/"+seedID, "")); got.Author != tt.wantAuthor {
+			t.Errorf("%q: existing book's author %q, want %q", tt.query, got.Author, tt.wantAuthor)
+		}
+		if total := decode[BookPage](t, do(t, router, http.MethodGet, "/v1/book", "")).Total; total != tt.wantTotal {
+			t.Errorf("%q: %d books stored, want %d", tt.query, total, tt.wantTotal)
+		}
+	}
+
+	router := newSeededRouter(t)
+	w := importFile(t, router, "", `[{"name":"Persuasion","author":"Austen"},{"name":"","author":"Nobody"}]`)
+	if got := decode[ImportResult](t, w); w.Code != http.StatusOK || got.Imported != 1 || got.ErrorCount != 1 || got.Errors[0].Row != 2 {
+		t.Errorf("JSON file: status %d, body %s", w.Code, w.Body)
+	}
+	if w := importFile(t, router, "?conflict=replace", csv); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown policy: status %d, want 400", w.Code)
+	}
+}
+
+// batchCountingStore counts CreateBatch calls.
+type batchCountingStore struct {
+	BookStore
+	batches int
+}
+
+func (s *batchCountingStore) CreateBatch(ctx context.Context, books []Book) error {
+	s.batches++
+	return s.BookStore.CreateBatch(ctx, books)
+}
+
+func TestImportChecksConflictsUpFront(t *testing.T) {
+	const csv = "id,name,author,isbn\n" +
+		seedID + ",Emma,Jane Austen,\n" +
+		"33333333-3333-3333-3333-333333333333,Dune Messiah,Herbert,9780441172719\n" +
+		"44444444-4444-4444-4444-444444444444,Persuasion,Austen,9780306406157\n" +
+		"55555555-5555-5555-5555-555555555555,Sanditon,Austen,978-0-306-40615-7\n" +
+		"66666666-6666-6666-6666-666666666666,dune,Someone,\n" +
+		",Lady Susan,Austen,\n"
+
+	for _, tt := range []struct {
+		policy      string
+		wantStatus  int
+		wantBatches int
+		want        ImportResult
+	}{
+		{conflictSkip, http.StatusOK, 1, ImportResult{Imported: 2, SkippedCount: 4}},
+		{conflictOverwrite, http.StatusOK, 1, ImportResult{Imported: 2, Overwritten: 1, ErrorCount: 3}},
+		{conflictFail, http.StatusConflict, 0, ImportResult{}},
+	} {
+		counting := &batchCountingStore{BookStore: NewMemoryStore()}
+		router := setupRouter(newStoreService(t, newUniqueNameStore(counting)))
+		postBook(t, router, `{"id":"`+seedID+`","name":"Emma","author":"Austen"}`)
+		postBook(t, router, `{"name":"Dune","author":"Herbert","isbn":"9780441172719"}`)
+
+		w := importFile(t, router, "?conflict="+tt.policy, csv)
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status %d, body %s", tt.policy, w.Code, w.Body)
+			continue
+		}
+		if counting.batches != tt.wantBatches {
+			t.Errorf("%s: %d CreateBatch calls, want %d", tt.policy, counting.batches, tt.wantBatches)
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+
+		got := decode[ImportResult](t, w)
+		if got.Imported != tt.want.Imported || got.SkippedCount != tt.want.SkippedCount ||
+			got.Overwritten != tt.want.Overwritten || got.ErrorCount != tt.want.ErrorCount {
+			t.Errorf("%s: summary %s", tt.policy, w.Body)
+		}
+
+		var rows []int
+		for _, row := range append(got.Skipped, got.Errors...) {
+			rows = append(rows, row.Row)
+		}
+		slices.Sort(rows)
+		if want := []int{2, 4, 5}; tt.policy == conflictOverwrite && !slices.Equal(rows, want) {
+			t.Errorf("%s: rows %v not imported, want %v", tt.policy, rows, want)
+		}
+		if total := decode[BookPage](t, do(t, router, http.MethodGet, "/v1/book", "")).Total; total != 4 {
+			t.Errorf("%s: %d books stored, want 4", tt.policy, total)
+		}
+	}
+}
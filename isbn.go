@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+var errInvalidISBN = errors.New("isbn must be a valid ISBN-10 or ISBN-13 with a correct check digit")
+
+func normalizeISBN(isbn string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(isbn)
+}
+
+func validateISBN(isbn string) error {
+	isbn = normalizeISBN(isbn)
+
+	switch len(isbn) {
+	case 10:
+		return validateISBN10(isbn)
+	case 13:
+		return validateISBN13(isbn)
+	default:
+		return errInvalidISBN
+	}
+}
+
+func validateISBN10(isbn string) error {
+	sum := 0
+
+	for i, r := range isbn {
+		var digit int
+		switch {
+		case r >= '0' && r <= '9':
+			digit = int(r - '0')
+		case (r == 'X' || r == 'x') && i == 9:
+			digit = 10
+		default:
+			return errInvalidISBN
+		}
+		sum += (10 - i) * digit
+	}
+
+	if sum%11 != 0 {
+		return errInvalidISBN
+	}
+
+	return nil
+}
+
+func validateISBN13(isbn string) error {
+	sum := 0
+
+	for i, r := range isbn {
+		if r < '0' || r > '9' {
+			return errInvalidISBN
+		}
+
+		digit := int(r - '0')
+		if i%2 == 1 {
+			digit *= 3
+		}
+		sum += digit
+	}
+
+	if sum%10 != 0 {
+		return errInvalidISBN
+	}
+
+	return nil
+}
+
+// checkISBN validates book.ISBN and stores it in canonical form, without
+// separators and with an upper-case X, so equal ISBNs compare equal.
+func (bs *BookService) checkISBN(book *Book) error {
+	if book.ISBN == "" {
+		if bs.RequireISBN {
+			return errors.New("isbn is required")
+		}
+		return nil
+	}
+
+	if err := validateISBN(book.ISBN); err != nil {
+		return err
+	}
+
+	book.ISBN = strings.ToUpper(normalizeISBN(book.ISBN))
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestValidateISBN(t *testing.T) {
+	for _, isbn := range []string{
+		"0306406152",
+		"0-306-40615-2",
+		"080442957X",
+		"080442957x",
+		"978-0-306-40615-7",
+		"978 0 306 40615 7",
+	} {
+		if err := validateISBN(isbn); err != nil {
+			t.Errorf("validateISBN(%q) = %v, want nil", isbn, err)
+		}
+	}
+
+	for _, isbn := range []string{
+		"0306406153",    // right length, wrong check digit
+		"9780306406158", // right length, wrong check digit
+		"X306406152",    // X is only a check digit
+		"978030640615X", // and only in ISBN-10
+		"97803064061A7",
+		"030640615",
+		"",
+	} {
+		if err := validateISBN(isbn); err == nil {
+			t.Errorf("validateISBN(%q) = nil, want an error", isbn)
+		}
+	}
+}
+
+func TestCreateISBN(t *testing.T) {
+	bs := newTestService(t)
+	router := setupRouter(bs)
+
+	book := postBook(t, router, `{"name":"Emma","author":"Austen","isbn":"0-8044-2957-x"}`)
+	if book.ISBN != "080442957X" {
+		t.Errorf("stored ISBN %q, want it normalized", book.ISBN)
+	}
+	postBook(t, router, `{"name":"Dune","author":"Herbert"}`)
+
+	w := do(t, router, http.MethodPost, "/v1/book", `{"name":"Dune","author":"Herbert","isbn":"0306406153"}`)
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeValidationFailed {
+		t.Errorf("bad checksum: status %d, body %s", w.Code, w.Body)
+	}
+
+	w = do(t, router, http.MethodPut, "/v1/book/"+book.ID, `{"name":"Emma","author":"Austen","isbn":"123"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("update with a bad ISBN: status %d, body %s", w.Code, w.Body)
+	}
+
+	bs.RequireISBN = true
+	if w := do(t, router, http.MethodPost, "/v1/book", `{"name":"Dune","author":"Herbert"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("missing ISBN with -require-isbn: status %d, want 400", w.Code)
+	}
+}
+
+func TestISBNUniqueness(t *testing.T) {
+	for name, store := range storeBackends(t) {
+		router := setupRouter(newStoreService(t, store))
+
+		book := postBook(t, router, `{"name":"Dune","author":"Herbert","isbn":"978-0-441-17271-9"}`)
+		if book.ISBN != "9780441172719" {
+			t.Errorf("%s: ISBN-13 stored as %q", name, book.ISBN)
+		}
+		other := postBook(t, router, `{"name":"Emma","author":"Austen"}`)
+		postBook(t, router, `{"name":"Persuasion","author":"Austen"}`)
+
+		if w := do(t, router, http.MethodPost, "/v1/book", `{"name":"Dune","author":"Herbert","isbn":"9780441172718"}`); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: ISBN-13 with a bad checksum: status %d, want 400", name, w.Code)
+		}
+
+		for _, tt := range []struct{ method, path, body string }{
+			{http.MethodPost, "/v1/book", `{"name":"Dune Again","author":"Herbert","isbn":"9780441172719"}`},
+			{http.MethodPut, "/v1/book/" + other.ID, `{"name":"Emma","author":"Austen","isbn":"978 0 441 17271 9"}`},
+		} {
+			w := do(t, router, tt.method, tt.path, tt.body)
+			if w.Code != http.StatusConflict || errorCode(t, w) != CodeConflict {
+				t.Errorf("%s: %s %s with a taken ISBN: status %d, body %s; want 409", name, tt.method, tt.path, w.Code, w.Body)
+			}
+		}
+
+		w := do(t, router, http.MethodPut, "/v1/book/"+book.ID, `{"name":"Dune","author":"Frank Herbert","isbn":"9780441172719"}`)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: update keeping its own ISBN: status %d, body %s", name, w.Code, w.Body)
+		}
+	}
+}
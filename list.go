@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+type BookPage struct {
+	XMLName xml.Name `json:"-" xml:"books"`
+
+	Data   []Book `json:"data" xml:"book"`
+	Total  int    `json:"total" xml:"total,attr"`
+	Limit  int    `json:"limit" xml:"limit,attr"`
+	Offset int    `json:"offset" xml:"offset,attr"`
+}
+
+type BookFilter struct {
+	Author         string
+	AuthorContains string
+	Name           string
+	IncludeDeleted bool
+}
+
+func bookFilterFromQuery(c *gin.Context) BookFilter {
+	return BookFilter{
+		Author:         c.Query("author"),
+		AuthorContains: c.Query("author_contains"),
+		Name:           c.Query("name"),
+		IncludeDeleted: c.Query("include_deleted") == "true",
+	}
+}
+
+func (f BookFilter) match(book Book) bool {
+	if book.deleted() && !f.IncludeDeleted {
+		return false
+	}
+
+	if f.Author != "" && !strings.EqualFold(book.Author, f.Author) {
+		return false
+	}
+
+	if f.AuthorContains != "" &&
+		!strings.Contains(strings.ToLower(book.Author), strings.ToLower(f.AuthorContains)) {
+		return false
+	}
+
+	if f.Name != "" &&
+		!strings.Contains(strings.ToLower(book.Name), strings.ToLower(f.Name)) {
+		return false
+	}
+
+	return true
+}
+
+func filterBooks(books []Book, f BookFilter) []Book {
+	filtered := make([]Book, 0, len(books))
+
+	for _, book := range books {
+		if f.match(book) {
+			filtered = append(filtered, book)
+		}
+	}
+
+	return filtered
+}
+
+const noMatch = -1
+
+// matchRank scores how well field matches the lowercased query: 0 for an
+// exact match, 1 for a prefix, 2 for a substring and noMatch otherwise.
+func matchRank(field, q string) int {
+	field = strings.ToLower(field)
+
+	switch {
+	case field == q:
+		return 0
+	case strings.HasPrefix(field, q):
+		return 1
+	case strings.Contains(field, q):
+		return 2
+	default:
+		return noMatch
+	}
+}
+
+type searchHit struct {
+	book       Book
+	rank       int
+	authorOnly bool
+}
+
+// searchBooks returns books whose name or author contains q, case-insensitively.
+// Results are ranked by the better of the two fields (exact, then prefix, then
+// substring); within a rank name matches come before author-only matches and
+// remaining ties are ordered by ID.
+func searchBooks(books []Book, q string) []Book {
+	q = strings.ToLower(q)
+
+	hits := make([]searchHit, 0, len(books))
+
+	for _, book := range books {
+		nameRank := matchRank(book.Name, q)
+		authorRank := matchRank(book.Author, q)
+
+		switch {
+		case nameRank == noMatch && authorRank == noMatch:
+			continue
+		case nameRank == noMatch || (authorRank != noMatch && authorRank < nameRank):
+			hits = append(hits, searchHit{book: book, rank: authorRank, authorOnly: nameRank == noMatch})
+		default:
+			hits = append(hits, searchHit{book: book, rank: nameRank})
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].rank != hits[j].rank {
+			return hits[i].rank < hits[j].rank
+		}
+		if hits[i].authorOnly != hits[j].authorOnly {
+			return !hits[i].authorOnly
+		}
+		return hits[i].book.ID < hits[j].book.ID
+	})
+
+	results := make([]Book, len(hits))
+	for i, hit := range hits {
+		results[i] = hit.book
+	}
+
+	return results
+}
+
+func parsePage(limitParam, offsetParam string) (limit, offset int, err error) {
+	limit, offset = defaultPageLimit, 0
+
+	if limitParam != "" {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 0 {
+			return 0, 0, errors.New("limit must be a non-negative integer")
+		}
+		if limit == 0 {
+			limit = defaultPageLimit
+		}
+		if limit > maxPageLimit {
+			limit = maxPageLimit
+		}
+	}
+
+	if offsetParam != "" {
+		offset, err = strconv.Atoi(offsetParam)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+	}
+
+	return limit, offset, nil
+}
+
+var sortFields = []string{"id", "name", "author"}
+
+var sortKeys = map[string]func(Book) string{
+	"id":     func(b Book) string { return b.ID },
+	"name":   func(b Book) string { return b.Name },
+	"author": func(b Book) string { return b.Author },
+}
+
+func sortBooks(books []Book, sortParam string) error {
+	if sortParam == "" {
+		sortParam = "id"
+	}
+
+	field := strings.TrimPrefix(sortParam, "-")
+	desc := field != sortParam
+
+	key, ok := sortKeys[field]
+	if !ok {
+		return fmt.Errorf("unknown sort field %q, allowed fields: %s",
+			field, strings.Join(sortFields, ", "))
+	}
+
+	sort.Slice(books, func(i, j int) bool {
+		a, b := key(books[i]), key(books[j])
+		if a == b {
+			return books[i].ID < books[j].ID
+		}
+		if desc {
+			return a > b
+		}
+		return a < b
+	})
+
+	return nil
+}
+
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+
+	return items[offset:end]
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// seedBooks stores n books straight into bs.Store, with IDs that sort in the
+// order they were created.
+func seedBooks(t *testing.T, bs *BookService, n int) []Book {
+	t.Helper()
+
+	books := make([]Book, n)
+	for i := range books {
+		books[i] = Book{
+			ID:      fmt.Sprintf("%08d-0000-0000-0000-000000000000", i),
+			Name:    fmt.Sprintf("Book %d", i),
+			Author:  "Author",
+			Version: 1,
+		}
+		if err := bs.Store.Create(context.Background(), books[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return books
+}
+
+func TestListPagination(t *testing.T) {
+	bs := newTestService(t)
+	books := seedBooks(t, bs, 120)
+	router := setupRouter(bs)
+
+	w := do(t, router, http.MethodGet, "/v1/book", "")
+	page := decode[BookPage](t, w)
+	if page.Limit != defaultPageLimit || page.Offset != 0 || page.Total != 120 || len(page.Data) != defaultPageLimit {
+		t.Errorf("default page: limit %d, offset %d, total %d, %d books", page.Limit, page.Offset, page.Total, len(page.Data))
+	}
+	if page.Data[0].ID != books[0].ID || page.Data[49].ID != books[49].ID {
+		t.Errorf("default page is not the first %d books by ID", defaultPageLimit)
+	}
+	if total := w.Header().Get("X-Total-Count"); total != "120" {
+		t.Errorf("X-Total-Count %q", total)
+	}
+
+	w = do(t, router, http.MethodGet, "/v1/book?offset=500", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"data":[]`) {
+		t.Errorf("offset past the end: status %d, body %s", w.Code, w.Body)
+	}
+
+	w = do(t, router, http.MethodGet, "/v1/book?limit=100000", "")
+	if page := decode[BookPage](t, w); page.Limit != maxPageLimit || len(page.Data) != 120 {
+		t.Errorf("over-cap limit: limit %d, %d books", page.Limit, len(page.Data))
+	}
+}
+
+func TestListSecondPage(t *testing.T) {
+	bs := newTestService(t)
+	books := seedBooks(t, bs, 100)
+	router := setupRouter(bs)
+
+	w := do(t, router, http.MethodGet, "/v1/book?limit=20&offset=20", "")
+	page := decode[BookPage](t, w)
+	if page.Total != 100 || page.Limit != 20 || page.Offset != 20 || len(page.Data) != 20 {
+		t.Fatalf("page = total %d, limit %d, offset %d, %d books", page.Total, page.Limit, page.Offset, len(page.Data))
+	}
+	for i, book := range page.Data {
+		if book.ID != books[20+i].ID {
+			t.Errorf("data[%d] = %s, want %s", i, book.ID, books[20+i].ID)
+		}
+	}
+
+	for _, query := range []string{"limit=-1", "limit=ten", "offset=-5", "offset=1.5"} {
+		w := do(t, router, http.MethodGet, "/v1/book?"+query, "")
+		if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeBadRequest {
+			t.Errorf("%s: status %d, body %s", query, w.Code, w.Body)
+		}
+	}
+}
+
+// listNames returns the names of the books GET /v1/book?query lists.
+func listNames(t *testing.T, h http.Handler, query string) []string {
+	t.Helper()
+
+	w := do(t, h, http.MethodGet, "/v1/book?"+query, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /v1/book?%s: status %d, body %s", query, w.Code, w.Body)
+	}
+
+	names := []string{}
+	for _, book := range decode[BookPage](t, w).Data {
+		names = append(names, book.Name)
+	}
+
+	return names
+}
+
+// newLibraryRouter returns a router over a few books by different authors,
+// created in an order that differs from their IDs.
+func newLibraryRouter(t *testing.T) http.Handler {
+	t.Helper()
+
+	router := setupRouter(newTestService(t))
+	for _, body := range []string{
+		`{"id":"44444444-4444-4444-4444-444444444444","name":"The Hobbit","author":"J.R.R. Tolkien"}`,
+		`{"id":"22222222-2222-2222-2222-222222222222","name":"Persuasion","author":"Jane Austen"}`,
+		`{"id":"33333333-3333-3333-3333-333333333333","name":"Emma","author":"Austen"}`,
+		`{"id":"11111111-1111-1111-1111-111111111111","name":"Dune","author":"Frank Herbert"}`,
+	} {
+		postBook(t, router, body)
+	}
+
+	return router
+}
+
+func TestListFilterByAuthor(t *testing.T) {
+	router := newLibraryRouter(t)
+
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"author=austen", []string{"Emma"}},
+		{"author=JANE%20AUSTEN", []string{"Persuasion"}},
+		{"author_contains=AUST", []string{"Persuasion", "Emma"}},
+		{"author=Tolkien", []string{}},
+	} {
+		if got := listNames(t, router, tt.query); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	if w := do(t, router, http.MethodGet, "/v1/book?author=Nobody", ""); !strings.Contains(w.Body.String(), `"data":[]`) {
+		t.Errorf("no match: body %s, want an empty array", w.Body)
+	}
+}
+
+func TestListSortByAuthor(t *testing.T) {
+	router := newLibraryRouter(t)
+
+	for query, want := range map[string]string{
+		"sort=author":          "Emma,Dune,The Hobbit,Persuasion",
+		"sort=-author":         "Persuasion,The Hobbit,Dune,Emma",
+		"sort=-author&limit=2": "Persuasion,The Hobbit",
+	} {
+		if got := strings.Join(listNames(t, router, query), ","); got != want {
+			t.Errorf("%s: %s, want %s", query, got, want)
+		}
+	}
+
+	w := do(t, router, http.MethodGet, "/v1/book?sort=year", "")
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeBadRequest {
+		t.Errorf("unknown sort field: status %d, body %s", w.Code, w.Body)
+	}
+}
+
+func TestListSortByName(t *testing.T) {
+	router := newLibraryRouter(t)
+	postBook(t, router, `{"id":"00000000-0000-0000-0000-000000000000","name":"Emma","author":"Anon"}`)
+
+	for query, want := range map[string]string{
+		"":           "Emma,Dune,Persuasion,Emma,The Hobbit",
+		"sort=id":    "Emma,Dune,Persuasion,Emma,The Hobbit",
+		"sort=name":  "Dune,Emma,Emma,Persuasion,The Hobbit",
+		"sort=-name": "The Hobbit,Persuasion,Emma,Emma,Dune",
+	} {
+		if got := strings.Join(listNames(t, router, query), ","); got != want {
+			t.Errorf("%q: %s, want %s", query, got, want)
+		}
+	}
+
+	// Equal names fall back to ID order.
+	w := do(t, router, http.MethodGet, "/v1/book?sort=-name", "")
+	if data := decode[BookPage](t, w).Data; data[2].Author != "Anon" || data[3].Author != "Austen" {
+		t.Errorf("tie broken as %s, %s; want by ID", data[2].Author, data[3].Author)
+	}
+
+	w = do(t, router, http.MethodGet, "/v1/book?sort=-title", "")
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "allowed fields: id, name, author") {
+		t.Errorf("unknown sort field: status %d, body %s", w.Code, w.Body)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	router := setupRouter(newTestService(t))
+	for _, body := range []string{
+		`{"id":"11111111-1111-1111-1111-111111111111","name":"Ring of Fire","author":"Flint"}`,
+		`{"id":"22222222-2222-2222-2222-222222222222","name":"Dune","author":"Frank Herbert"}`,
+		`{"id":"33333333-3333-3333-3333-333333333333","name":"Silmarillion","author":"Ringo Starr"}`,
+		`{"id":"44444444-4444-4444-4444-444444444444","name":"The Lord of the Rings","author":"J.R.R. Tolkien"}`,
+	} {
+		postBook(t, router, body)
+	}
+
+	search := func(query string) string {
+		w := do(t, router, http.MethodGet, "/v1/book/search?"+query, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("search %s: status %d, body %s", query, w.Code, w.Body)
+		}
+
+		var names []string
+		for _, book := range decode[BookPage](t, w).Data {
+			names = append(names, book.Name)
+		}
+		return strings.Join(names, ",")
+	}
+
+	for query, want := range map[string]string{
+		"q=herbert":               "Dune",
+		"q=DUNE":                  "Dune",
+		"q=ring":                  "Ring of Fire,Silmarillion,The Lord of the Rings",
+		"q=ring&limit=1&offset=1": "Silmarillion",
+		"q=tolkien":               "The Lord of the Rings",
+	} {
+		if got := search(query); got != want {
+			t.Errorf("%s: %s, want %s", query, got, want)
+		}
+	}
+
+	for _, query := range []string{"", "q=", "q=%20"} {
+		if w := do(t, router, http.MethodGet, "/v1/book/search?"+query, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%q: status %d, want 400", query, w.Code)
+		}
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	router := setupRouter(newTestService(t))
+	for _, body := range []string{
+		`{"id":"11111111-1111-1111-1111-111111111111","name":"Gemma's Garden","author":"Lee"}`,
+		`{"id":"22222222-2222-2222-2222-222222222222","name":"Letters","author":"Emma Stone"}`,
+		`{"id":"33333333-3333-3333-3333-333333333333","name":"Emma Stories","author":"Lee"}`,
+		`{"id":"44444444-4444-4444-4444-444444444444","name":"Poems","author":"Emma"}`,
+		`{"id":"55555555-5555-5555-5555-555555555555","name":"Emma","author":"Emma Tennant"}`,
+		`{"id":"66666666-6666-6666-6666-666666666666","name":"Nothing","author":"Dilemma Press"}`,
+		`{"id":"77777777-7777-7777-7777-777777777777","name":"Dune","author":"Frank Herbert"}`,
+	} {
+		postBook(t, router, body)
+	}
+
+	w := do(t, router, http.MethodGet, "/v1/book/search?q=EMMA", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", w.Code, w.Body)
+	}
+
+	// Exact, then prefix, then substring; name matches lead within a rank.
+	want := "Emma,Poems,Emma Stories,Letters,Gemma's Garden,Nothing"
+	var names []string
+	for _, book := range decode[BookPage](t, w).Data {
+		names = append(names, book.Name)
+	}
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("ranked %s, want %s", got, want)
+	}
+}
+
+func TestListFilterByName(t *testing.T) {
+	router := newLibraryRouter(t)
+	postBook(t, router, `{"id":"55555555-5555-5555-5555-555555555555","name":"Pride and Prejudice","author":"Jane Austen"}`)
+
+	for query, want := range map[string]string{
+		"name=PER":                      "Persuasion",
+		"name=e":                        "Dune,Persuasion,Emma,The Hobbit,Pride and Prejudice",
+		"author=jane%20austen&name=pre": "Pride and Prejudice",
+		"author=austen&name=pre":        "",
+		"name=e&limit=2&offset=1":       "Persuasion,Emma",
+	} {
+		if got := strings.Join(listNames(t, router, query), ","); got != want {
+			t.Errorf("%s: %s, want %s", query, got, want)
+		}
+	}
+
+	w := do(t, router, http.MethodGet, "/v1/book?name=e&limit=2", "")
+	if total := decode[BookPage](t, w).Total; total != 5 {
+		t.Errorf("total %d, want the filtered count 5", total)
+	}
+}
+
+func TestCount(t *testing.T) {
+	router := newLibraryRouter(t)
+	deleted := postBook(t, router, `{"name":"Lady Susan","author":"Austen"}`)
+	do(t, router, http.MethodDelete, "/v1/book/"+deleted.ID, "")
+
+	for query, want := range map[string]string{
+		"":                             `{"count":4}`,
+		"?author=austen":               `{"count":1}`,
+		"?author_contains=aus":         `{"count":2}`,
+		"?name=e&author=Jane%20Austen": `{"count":1}`,
+		"?include_deleted=true":        `{"count":5}`,
+	} {
+		w := do(t, router, http.MethodGet, "/v1/book/count"+query, "")
+		if w.Code != http.StatusOK || w.Body.String() != want {
+			t.Errorf("%q: status %d, body %s; want %s", query, w.Code, w.Body, want)
+		}
+	}
+}
+
+func TestCountMatchesList(t *testing.T) {
+	for name, store := range storeBackends(t) {
+		router := setupRouter(newStoreService(t, store))
+		for _, body := range []string{
+			`{"name":"Emma","author":"Jane Austen"}`,
+			`{"name":"Persuasion","author":"Jane Austen"}`,
+			`{"name":"Dune","author":"Frank Herbert"}`,
+		} {
+			postBook(t, router, body)
+		}
+		deleted := postBook(t, router, `{"name":"Lady Susan","author":"Jane Austen"}`)
+		do(t, router, http.MethodDelete, "/v1/book/"+deleted.ID, "")
+
+		for _, query := range []string{"", "author=jane%20austen", "name=u", "author=nobody", "include_deleted=true"} {
+			count := decode[struct{ Count int }](t, do(t, router, http.MethodGet, "/v1/book/count?"+query, "")).Count
+			total := decode[BookPage](t, do(t, router, http.MethodGet, "/v1/book?limit=1&"+query, "")).Total
+			if count != total {
+				t.Errorf("%s: ?%s counted %d, list total %d", name, query, count, total)
+			}
+		}
+	}
+}
@@ -1,154 +1,952 @@
 package main
 
 import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"io"
+	"net"
 	"net/http"
-	"sync"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
 )
 
 type Book struct {
-	ID     string `json:"id"`
-	Name   string `json:"name"`
-	Author string `json:"author"`
+	XMLName xml.Name `json:"-" xml:"book"`
+
+	ID     string `json:"id" xml:"id"`
+	Name   string `json:"name" xml:"name" binding:"required,max=200"`
+	Author string `json:"author" xml:"author" binding:"required,max=200"`
+	ISBN   string `json:"isbn" xml:"isbn"`
+
+	CreatedAt time.Time  `json:"created_at" xml:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" xml:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" xml:"deleted_at,omitempty"`
+	Version   int        `json:"version" xml:"version"`
+}
+
+func (b Book) GetID() string {
+	return b.ID
+}
+
+func (b *Book) SetID(id string) {
+	b.ID = id
+}
+
+func (b Book) deleted() bool {
+	return b.DeletedAt != nil
+}
+
+// clone returns a copy of b that shares no memory with it. Stores that keep
+// books in memory clone on the way in and out so callers can't change a
+// stored book through a returned one; new pointer, slice or map fields must
+// be copied here.
+func (b Book) clone() Book {
+	if b.DeletedAt != nil {
+		deletedAt := *b.DeletedAt
+		b.DeletedAt = &deletedAt
+	}
+
+	return b
+}
+
+type BookPatch struct {
+	Name   *string `json:"name" binding:"omitnil,min=1,max=200"`
+	Author *string `json:"author" binding:"omitnil,min=1,max=200"`
+	ISBN   *string `json:"isbn"`
 }
 
 type BookService struct {
-	Storage map[string]Book
-	Mu      *sync.RWMutex
-	Logger  *logrus.Logger
+	Store       BookStore
+	Logger      *logrus.Logger
+	RequireISBN bool
+	JWTSecret   []byte
+	APIKeys     []string
+	AuthMode    string
+	Now         func() time.Time
+
+	// EnableWipe mounts DELETE /book, which removes every book. It is also
+	// guarded by AdminKeys and stays unmounted while that is empty.
+	EnableWipe bool
+	AdminKeys  []string
+
+	// PutUpsert lets PUT create a book at a missing ID instead of
+	// answering 404.
+	PutUpsert bool
+
+	// ready is reported by /readyz. main sets it once startup is done and
+	// clears it as soon as shutdown begins.
+	ready atomic.Bool
+
+	// Stores for the entities served through RegisterCRUD; nil leaves the
+	// routes out.
+	Authors    Store[Author]
+	Publishers Store[Publisher]
+
+	// Events receives every book change; nil disables publishing and the
+	// event stream routes.
+	Events *EventHub
+
+	// Router settings read by setupRouter; zero values leave the
+	// corresponding middleware out.
+	Metrics      *Metrics
+	CORS         CORSConfig
+	RateLimiter  *RateLimiter
+	Idempotency  *IdempotencyCache
+	GzipMinSize  int
+	MaxBodyBytes int64
+	// RequestTimeout bounds every request except the streaming ones; see
+	// requestTimeout.
+	RequestTimeout time.Duration
+	// TrustedProxies is handed to gin; see resolveTrustedProxies. Nil
+	// trusts no proxy.
+	TrustedProxies []string
+}
+
+// newBookService wires a service to its store with metrics registered. The
+// remaining fields are optional and can be set before calling setupRouter.
+func newBookService(store BookStore, logger *logrus.Logger) *BookService {
+	metrics := NewMetrics()
+	metrics.RegisterBookCount(store)
+
+	return &BookService{
+		Store:      store,
+		Logger:     logger,
+		PutUpsert:  true,
+		Authors:    NewMemStore[Author](),
+		Publishers: NewMemStore[Publisher](),
+		Events:     NewEventHub(defaultEventBuffer),
+		Metrics:    metrics,
+	}
+}
+
+func (bs *BookService) now() time.Time {
+	if bs.Now != nil {
+		return bs.Now().UTC()
+	}
+
+	return time.Now().UTC()
 }
 
 func (bs *BookService) logError(err error, c *gin.Context, message string) {
-	bs.Logger.WithFields(logrus.Fields{
-		"error":    err.Error(),
-		"method":   c.Request.Method,
-		"endpoint": c.FullPath(),
+	logRequestError(bs.Logger, err, c, message)
+}
+
+func logRequestError(logger *logrus.Logger, err error, c *gin.Context, message string) {
+	logger.WithFields(logrus.Fields{
+		"error":      err.Error(),
+		"method":     c.Request.Method,
+		"endpoint":   c.FullPath(),
+		"request_id": c.GetString(requestIDKey),
 	}).Error(message)
 }
 
-func NoExist(exist bool, c *gin.Context) bool {
-	if !exist {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
-		return true
+// getActiveBook is GetByID that treats soft-deleted books as missing.
+func (bs *BookService) getActiveBook(ctx context.Context, id string) (Book, error) {
+	book, err := bs.Store.GetByID(ctx, id)
+	if err != nil {
+		return Book{}, err
+	}
+
+	if book.deleted() {
+		return Book{}, ErrNotFound
+	}
+
+	return book, nil
+}
+
+// duplicateMessage describes which uniqueness rule err broke, or returns ""
+// if it isn't a uniqueness error.
+func duplicateMessage(err error) string {
+	switch {
+	case errors.Is(err, ErrConflict):
+		return "Book with this ID already exists"
+	case errors.Is(err, ErrDuplicateISBN):
+		return "Book with this ISBN already exists"
+	case errors.Is(err, ErrDuplicateName):
+		return "Book with this name already exists"
+	default:
+		return ""
+	}
+}
+
+func (bs *BookService) storeError(err error, c *gin.Context) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		respondError(c, http.StatusNotFound, CodeNotFound, "Record not found")
+	case errors.Is(err, ErrConflict):
+		respondError(c, http.StatusConflict, CodeConflict, duplicateMessage(err))
+	case errors.Is(err, ErrDuplicateISBN), errors.Is(err, ErrDuplicateName):
+		respondError(c, http.StatusConflict, CodeConflict, duplicateMessage(err))
+	case errors.Is(err, ErrVersionConflict):
+		respondError(c, http.StatusConflict, CodeConflict, "Book was modified concurrently, retry the request")
+	case isContextError(err):
+		respondTimeout(c)
+	default:
+		respondError(c, http.StatusInternalServerError, CodeInternal, "Internal server error")
+		bs.logError(err, c, "Error when accessing storage")
+	}
+}
+
+// bookResource serves the book list, get, create, replace and delete routes
+// through the generic handlers. The hooks add what books have on top of plain
+// storage: soft deletes, versions checked through If-Match and the body,
+// ETags, filtering, ?fields= projections, content negotiation and events.
+//
+// Replace implements PUT as an upsert when PutUpsert is set: an unknown ID is
+// created at that ID (201), an existing one is replaced (200). A soft-deleted
+// book is 404 like on every other route; it has to be restored before it can
+// be replaced.
+func (bs *BookService) bookResource() *Resource[Book] {
+	return &Resource[Book]{
+		Store:      bs.Store,
+		Logger:     bs.Logger,
+		Name:       "Book",
+		Path:       apiVersionPrefix + "/book",
+		Upsert:     bs.PutUpsert,
+		StoreError: bs.storeError,
+		Visible: func(c *gin.Context, book Book) bool {
+			if !book.deleted() {
+				return true
+			}
+
+			return isRead(c) && c.Query("include_deleted") == "true"
+		},
+		Filter: func(c *gin.Context, books []Book) ([]Book, error) {
+			books = filterBooks(books, bookFilterFromQuery(c))
+			return books, sortBooks(books, c.Query("sort"))
+		},
+		Prepare: func(c *gin.Context, book *Book) bool {
+			if err := bs.prepareNewBook(book); err != nil {
+				respondError(c, http.StatusBadRequest, CodeValidationFailed, err.Error())
+				return false
+			}
+
+			return true
+		},
+		Replace: func(c *gin.Context, existing Book, book *Book) bool {
+			if err := bs.checkISBN(book); err != nil {
+				respondError(c, http.StatusBadRequest, CodeValidationFailed, err.Error())
+				return false
+			}
+
+			// A version in the body is the one the client read; a stale one is
+			// rejected with the current version so the client can rebase.
+			if book.Version != 0 && book.Version != existing.Version {
+				respondErrorDetails(c, http.StatusConflict, CodeConflict,
+					"Book has been modified since the version in the request body",
+					gin.H{"current_version": existing.Version})
+				return false
+			}
+
+			book.CreatedAt = existing.CreatedAt
+			book.UpdatedAt = bs.now()
+			book.DeletedAt = nil
+			book.Version = existing.Version + 1
+
+			return true
+		},
+		Delete:  bs.markDeleted,
+		ETag:    bookETag,
+		IfMatch: ifMatch,
+		Changed: func(event string, book Book) {
+			bs.publish(event, book)
+		},
+		Render: func(c *gin.Context, status int, book Book) {
+			// Only reads take ?fields=, and a projection is always rendered
+			// as JSON.
+			fields, err := bs.readFields(c)
+			if err != nil {
+				return
+			}
+
+			if fields == nil {
+				renderBook(c, status, book)
+				return
+			}
+
+			projected, err := projectBook(book, fields)
+			if err != nil {
+				bs.storeError(err, c)
+				return
+			}
+
+			c.JSON(status, projected)
+		},
+		RenderPage: func(c *gin.Context, page Page[Book]) {
+			fields, err := bs.readFields(c)
+			if err != nil {
+				return
+			}
+
+			c.Header("X-Total-Count", strconv.Itoa(page.Total))
+			bs.renderPage(c, BookPage{
+				Data:   page.Data,
+				Total:  page.Total,
+				Limit:  page.Limit,
+				Offset: page.Offset,
+			}, fields)
+		},
+	}
+}
+
+func isRead(c *gin.Context) bool {
+	return c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead
+}
+
+// readFields parses ?fields= on reads, answering 400 if it is invalid. Writes
+// always render the whole book.
+func (bs *BookService) readFields(c *gin.Context) (map[string]bool, error) {
+	if !isRead(c) {
+		return nil, nil
+	}
+
+	fields, err := parseFields(c.Query("fields"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeBadRequest, err.Error())
 	}
 
-	return false
+	return fields, err
 }
 
-func (bs *BookService) returnAllBooks(c *gin.Context) {
-	bs.Mu.RLock()
-	defer bs.Mu.RUnlock()
+// renderPage is renderBookPage with an optional ?fields= projection, which
+// like a projected single book is always rendered as JSON.
+func (bs *BookService) renderPage(c *gin.Context, page BookPage, fields map[string]bool) {
+	if fields == nil {
+		renderBookPage(c, http.StatusOK, page)
+		return
+	}
+
+	projected, err := projectPage(page, fields)
+	if err != nil {
+		bs.storeError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, projected)
+}
+
+func (bs *BookService) countBooks(c *gin.Context) {
+	filter := bookFilterFromQuery(c)
+
+	// Without filters the store can count on its own.
+	if filter == (BookFilter{}) {
+		n, err := bs.Store.Count(c.Request.Context())
+		if err != nil {
+			bs.storeError(err, c)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"count": n})
+		return
+	}
+
+	books, err := bs.Store.GetAll(c.Request.Context())
+	if err != nil {
+		bs.storeError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"count": len(filterBooks(books, filter))})
+}
+
+func (bs *BookService) searchBooks(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		respondError(c, http.StatusBadRequest, CodeBadRequest, "Query parameter q is required")
+		return
+	}
+
+	limit, offset, err := parsePage(c.Query("limit"), c.Query("offset"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeBadRequest, err.Error())
+		return
+	}
+
+	fields, err := parseFields(c.Query("fields"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeBadRequest, err.Error())
+		return
+	}
+
+	books, err := bs.Store.GetAll(c.Request.Context())
+	if err != nil {
+		bs.storeError(err, c)
+		return
+	}
+
+	books = searchBooks(filterBooks(books, BookFilter{}), q)
+
+	c.Header("X-Total-Count", strconv.Itoa(len(books)))
+	bs.renderPage(c, BookPage{
+		Data:   paginate(books, limit, offset),
+		Total:  len(books),
+		Limit:  limit,
+		Offset: offset,
+	}, fields)
+}
+
+// prepareNewBook checks the fields binding can't and fills in the
+// server-managed ones before a book is stored for the first time.
+func (bs *BookService) prepareNewBook(book *Book) error {
+	if err := bs.checkISBN(book); err != nil {
+		return err
+	}
+
+	if book.ID == "" {
+		book.ID = uuid.NewString()
+	} else if _, err := uuid.Parse(book.ID); err != nil {
+		return errors.New("Book ID must be a valid UUID")
+	}
+
+	book.CreatedAt = bs.now()
+	book.UpdatedAt = book.CreatedAt
+	book.DeletedAt = nil
+	book.Version = 1
+
+	return nil
+}
+
+type ItemError struct {
+	Index  int      `json:"index"`
+	Error  string   `json:"error"`
+	Fields []string `json:"fields,omitempty"`
+}
+
+func (bs *BookService) createBooksBatch(c *gin.Context) {
 
 	var books []Book
+	if err := decodeStrictJSON(c, &books); err != nil {
+		bs.bindError(err, c)
+		return
+	}
+
+	if len(books) == 0 {
+		respondError(c, http.StatusBadRequest, CodeBadRequest, "Batch must contain at least one book")
+		return
+	}
 
-	for _, book := range bs.Storage {
-		books = append(books, book)
+	var itemErrors []ItemError
+
+	for i := range books {
+		if err := binding.Validator.ValidateStruct(&books[i]); err != nil {
+			fields, _ := validationFields(err)
+			itemErrors = append(itemErrors, ItemError{
+				Index:  i,
+				Error:  "Validation failed: " + strings.Join(fields, "; "),
+				Fields: fields,
+			})
+			continue
+		}
+
+		if err := bs.prepareNewBook(&books[i]); err != nil {
+			itemErrors = append(itemErrors, ItemError{Index: i, Error: err.Error()})
+		}
 	}
 
+	if len(itemErrors) > 0 {
+		respondErrorDetails(c, http.StatusBadRequest, CodeValidationFailed,
+			"Batch rejected: some books are invalid", itemErrors)
+		return
+	}
+
+	if err := bs.Store.CreateBatch(c.Request.Context(), books); err != nil {
+		var batchErr *BatchError
+		if message := duplicateMessage(err); errors.As(err, &batchErr) && message != "" {
+			respondErrorDetails(c, http.StatusConflict, CodeConflict,
+				"Batch rejected: a book conflicts with another book", []ItemError{{
+					Index: batchErr.Index,
+					Error: message,
+				}})
+			return
+		}
+
+		bs.storeError(err, c)
+		return
+	}
+
+	bs.publish(eventCreated, books...)
 	c.JSON(http.StatusOK, books)
 }
 
-func (bs *BookService) returnBooksByID(c *gin.Context) {
+func (bs *BookService) patchBook(c *gin.Context) {
 
 	bookID := c.Param("id")
 
-	bs.Mu.RLock()
-	book, exist := bs.Storage[bookID]
-	bs.Mu.RUnlock()
+	var (
+		patch BookPatch
+		err   error
+	)
+
+	merge := c.ContentType() == mimeMergePatch
+	switch {
+	case merge:
+		patch, err = decodeMergePatch(c)
+	case c.ContentType() == binding.MIMEJSON, c.ContentType() == "":
+		err = bindStrictJSON(c, &patch)
+	default:
+		respondError(c, http.StatusUnsupportedMediaType, CodeUnsupportedMedia,
+			"Content-Type must be "+binding.MIMEJSON+" or "+mimeMergePatch)
+		return
+	}
+	if err != nil {
+		bs.bindError(err, c)
+		return
+	}
 
-	if NoExist(exist, c) {
+	book, err := bs.getActiveBook(c.Request.Context(), bookID)
+	if err != nil {
+		bs.storeError(err, c)
 		return
 	}
 
-	c.JSON(http.StatusOK, book)
-}
+	if !ifMatch(c.GetHeader("If-Match"), book) {
+		respondError(c, http.StatusPreconditionFailed, CodePreconditionFailed,
+			"Book has been modified since the version in If-Match")
+		return
+	}
 
-func (bs *BookService) createBook(c *gin.Context) {
+	if patch.Name != nil {
+		book.Name = *patch.Name
+	}
+
+	if patch.Author != nil {
+		book.Author = *patch.Author
+	}
+
+	if patch.ISBN != nil {
+		book.ISBN = *patch.ISBN
+	}
+
+	// A merge patch can null out required fields, which only shows once it
+	// has been applied.
+	if merge {
+		if err := binding.Validator.ValidateStruct(&book); err != nil {
+			bs.bindError(err, c)
+			return
+		}
+	}
 
-	var newBook Book
-	if err := c.ShouldBindJSON(&newBook); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format"})
-		bs.logError(err, c, "Error when decoding JSON")
+	if err := bs.checkISBN(&book); err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidationFailed, err.Error())
 		return
 	}
 
-	if _, exists := bs.Storage[newBook.ID]; exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Record already exists"})
+	book.UpdatedAt = bs.now()
+	book.Version++
+
+	if err := bs.Store.Update(c.Request.Context(), bookID, book); err != nil {
+		bs.storeError(err, c)
 		return
 	}
 
-	bs.Mu.Lock()
-	bs.Storage[newBook.ID] = newBook
-	bs.Mu.Unlock()
+	bs.publish(eventUpdated, book)
+	renderBook(c, http.StatusOK, book)
+}
+
+// softDelete marks the active book id as deleted and publishes the change.
+func (bs *BookService) softDelete(ctx context.Context, id string) error {
+	book, err := bs.getActiveBook(ctx, id)
+	if err == nil {
+		book, err = bs.markDeleted(ctx, book)
+	}
+	if err != nil {
+		return err
+	}
 
-	c.JSON(http.StatusOK, newBook)
+	bs.publish(eventDeleted, book)
+	return nil
 }
 
-func (bs *BookService) updateBook(c *gin.Context) {
+// markDeleted stores book as soft-deleted and returns it as stored.
+func (bs *BookService) markDeleted(ctx context.Context, book Book) (Book, error) {
+	deletedAt := bs.now()
+	book.DeletedAt = &deletedAt
+	book.Version++
 
-	bookID := c.Param("id")
+	if err := bs.Store.Update(ctx, book.ID, book); err != nil {
+		return Book{}, err
+	}
+
+	return book, nil
+}
+
+// deleteAllBooks wipes the store, which is only meant for test
+// environments, so it is only routed with EnableWipe, needs an admin key and
+// insists on ?confirm=true. It publishes no events.
+func (bs *BookService) deleteAllBooks(c *gin.Context) {
+	if c.Query("confirm") != "true" {
+		respondError(c, http.StatusBadRequest, CodeBadRequest,
+			"Deleting every book requires confirm=true")
+		return
+	}
+
+	deleted, err := bs.Store.DeleteAll(c.Request.Context())
+	if err != nil {
+		bs.storeError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
+
+type BulkDeleteRequest struct {
+	IDs []string `json:"ids"`
+}
 
-	var updatedBook Book
-	if err := c.ShouldBindJSON(&updatedBook); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format"})
-		bs.logError(err, c, "Error when decoding JSON")
+func (bs *BookService) deleteBooksBulk(c *gin.Context) {
+
+	var req BulkDeleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bs.bindError(err, c)
 		return
 	}
 
-	bs.Mu.Lock()
-	defer bs.Mu.Unlock()
+	if len(req.IDs) == 0 {
+		respondError(c, http.StatusBadRequest, CodeBadRequest, "ids must contain at least one ID")
+		return
+	}
 
-	_, exist := bs.Storage[bookID]
+	ids := make([]string, 0, len(req.IDs))
+	seen := make(map[string]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
 
-	if NoExist(exist, c) {
+	deleted, err := bs.Store.SoftDeleteBatch(c.Request.Context(), ids, bs.now())
+	if err != nil {
+		bs.storeError(err, c)
 		return
 	}
 
-	bs.Storage[bookID] = updatedBook
+	// The books are already deleted, so their events go out even if the
+	// request's deadline passes meanwhile.
+	eventCtx := context.WithoutCancel(c.Request.Context())
+
+	deletedSet := make(map[string]bool, len(deleted))
+	for _, id := range deleted {
+		deletedSet[id] = true
+
+		if bs.Events != nil {
+			if book, err := bs.Store.GetByID(eventCtx, id); err == nil {
+				bs.publish(eventDeleted, book)
+			}
+		}
+	}
 
-	c.JSON(http.StatusOK, updatedBook)
+	notFound := []string{}
+	for _, id := range ids {
+		if !deletedSet[id] {
+			notFound = append(notFound, id)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deleted":   deleted,
+		"not_found": notFound,
+	})
 }
 
-func (bs *BookService) deleteBook(c *gin.Context) {
+func (bs *BookService) restoreBook(c *gin.Context) {
 	bookID := c.Param("id")
 
-	bs.Mu.Lock()
-	defer bs.Mu.Unlock()
-
-	_, exist := bs.Storage[bookID]
+	book, err := bs.Store.GetByID(c.Request.Context(), bookID)
+	if err != nil {
+		bs.storeError(err, c)
+		return
+	}
 
-	if NoExist(exist, c) {
+	// Restoring a live book changes nothing, so there is nothing to publish.
+	if !book.deleted() {
+		renderBook(c, http.StatusOK, book)
 		return
 	}
 
-	delete(bs.Storage, bookID)
+	book.DeletedAt = nil
+	book.UpdatedAt = bs.now()
+	book.Version++
+
+	if err := bs.Store.Update(c.Request.Context(), bookID, book); err != nil {
+		bs.storeError(err, c)
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Book deleted successfully"})
+	bs.publish(eventUpdated, book)
+	renderBook(c, http.StatusOK, book)
 }
 
 func main() {
 
-	bs := &BookService{
-		Storage: make(map[string]Book),
-		Mu:      &sync.RWMutex{},
-		Logger:  logrus.New(),
+	addrFlag := flag.String("addr", "", "listen address (overrides ADDR, default "+defaultAddr+")")
+	logLevelFlag := flag.String("loglevel", "", "log level: debug, info, warn or error (overrides LOG_LEVEL, default "+defaultLogLevel+")")
+	backend := flag.String("backend", "", "storage backend: memory, sqlite, postgres or file")
+	dbPath := flag.String("db", "", "path to SQLite database file")
+	dataFile := flag.String("data-file", "", "path to JSON file for persisting books")
+	flag.StringVar(dataFile, "datafile", "", "alias for -data-file")
+	rateLimit := flag.Float64("rate", 0, "requests per second allowed per client IP (0 disables rate limiting)")
+	rateBurst := flag.Int("burst", 20, "burst size for the per-client rate limiter")
+	corsOrigins := flag.String("cors-origins", "", "comma-separated origins allowed to make cross-origin requests, or * (overrides CORS_ORIGINS)")
+	corsMethods := flag.String("cors-methods", "", "comma-separated methods allowed for cross-origin requests (overrides CORS_METHODS)")
+	corsHeaders := flag.String("cors-headers", "", "comma-separated request headers allowed for cross-origin requests (overrides CORS_HEADERS)")
+	corsCredentials := flag.Bool("cors-credentials", os.Getenv("CORS_CREDENTIALS") == "true", "allow credentialed cross-origin requests")
+	idempotencyTTL := flag.Duration("idempotency-ttl", defaultIdempotencyTTL, "how long responses to POST /book are kept for replay by Idempotency-Key (0 disables)")
+	maxBodyBytes := flag.Int64("max-body-bytes", defaultMaxBodyBytes, "largest request body accepted, in bytes (0 disables the limit)")
+	idempotencyMaxKeys := flag.Int("idempotency-max-keys", defaultIdempotencyMaxKeys, "most Idempotency-Key responses kept at once (0 means no limit)")
+	requestTimeout := flag.Duration("request-timeout", defaultRequestTimeout, "how long a request may run before it is answered with 503 (0 disables)")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "how long to wait for in-flight requests on shutdown")
+	apiKeys := flag.String("api-keys", "", "comma-separated API keys accepted in "+apiKeyHeader+" (overrides API_KEYS)")
+	authMode := flag.String("auth-mode", "", "which book routes need an API key: all or write (default all)")
+	gzipMinSize := flag.Int("gzip-min-size", defaultGzipMinSize, "smallest response in bytes to gzip for clients that accept it (0 disables compression)")
+	tlsCert := flag.String("tls-cert", "", "path to a PEM certificate; serve HTTPS when set together with -tls-key")
+	tlsKey := flag.String("tls-key", "", "path to the PEM private key for -tls-cert")
+	tlsMinVersion := flag.String("tls-min-version", defaultTLSMinVersion, "minimum TLS version: 1.0, 1.1, 1.2 or 1.3")
+	uniqueNames := flag.Bool("unique-names", false, "reject books whose name is already used by another book")
+	requireISBN := flag.Bool("require-isbn", false, "reject books without an ISBN")
+	trustedProxies := flag.String("trusted-proxies", defaultTrustedProxies, "comma-separated IPs or CIDRs of proxies whose X-Forwarded-For is trusted, or none")
+	putUpsert := flag.Bool("put-upsert", true, "let PUT /book/:id create a missing book (false answers 404 instead)")
+	enableWipe := flag.Bool("enable-wipe", false, "mount DELETE /book, which permanently removes every book; needs -admin-keys")
+	adminKeys := flag.String("admin-keys", "", "comma-separated keys accepted in "+adminKeyHeader+" for admin routes (overrides ADMIN_API_KEYS)")
+	grpcAddr := flag.String("grpc-addr", "", "address to serve the gRPC BookService on, e.g. :9090 (empty disables gRPC)")
+	flag.Parse()
+
+	registerValidation()
+
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.JSONFormatter{})
+
+	logLevel, err := resolveLogLevel(*logLevelFlag)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Fatal("Error when parsing the log level")
 	}
+	logger.SetLevel(logLevel)
 
-	bs.Logger.SetFormatter(&logrus.JSONFormatter{})
+	resolvedAuthMode, err := resolveAuthMode(*authMode)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Fatal("Error when parsing the auth mode")
+	}
 
-	router := gin.Default()
+	addr, err := resolveAddr(*addrFlag)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Fatal("Error when resolving the listen address")
+	}
 
-	router.GET("/book", bs.returnAllBooks)
-	router.GET("/book/:id", bs.returnBooksByID)
-	router.POST("/book", bs.createBook)
-	router.PUT("/book/:id", bs.updateBook)
-	router.DELETE("/book/:id", bs.deleteBook)
+	if err := validateBurst(*rateBurst); err != nil {
+		logger.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Fatal("Error when parsing the rate limit burst")
+	}
+
+	proxies, err := resolveTrustedProxies(*trustedProxies)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Fatal("Error when parsing the trusted proxies")
+	}
 
-	if err := http.ListenAndServe(":8080", router); err != nil {
-		logrus.WithFields(logrus.Fields{
+	tlsConfig, err := loadTLSConfig(*tlsCert, *tlsKey, *tlsMinVersion)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
 			"error": err.Error(),
+			"cert":  *tlsCert,
+			"key":   *tlsKey,
+		}).Fatal("Error when loading the TLS configuration")
+	}
+
+	store, err := openStore(*backend, *dbPath, *dataFile)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"error":   err.Error(),
+			"backend": *backend,
+		}).Fatal("Error when opening the storage backend")
+	}
+	if closer, ok := store.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	authors, err := openEntityStore[Author](store, "authors")
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Fatal("Error when opening the author store")
+	}
+
+	publishers, err := openEntityStore[Publisher](store, "publishers")
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Fatal("Error when opening the publisher store")
+	}
+
+	if *uniqueNames {
+		store = newUniqueNameStore(store)
+	}
+
+	bs := newBookService(store, logger)
+	bs.Authors = authors
+	bs.Publishers = publishers
+	bs.RequireISBN = *requireISBN
+	bs.PutUpsert = *putUpsert
+	bs.TrustedProxies = proxies
+	bs.AuthMode = resolvedAuthMode
+
+	bs.CORS = CORSConfig{
+		AllowedOrigins:   splitList(flagOrEnv(*corsOrigins, "CORS_ORIGINS")),
+		AllowedMethods:   splitList(flagOrEnv(*corsMethods, "CORS_METHODS")),
+		AllowedHeaders:   splitList(flagOrEnv(*corsHeaders, "CORS_HEADERS")),
+		AllowCredentials: *corsCredentials,
+	}
+	if err := bs.CORS.Validate(); err != nil {
+		bs.Logger.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Fatal("Error when configuring CORS")
+	}
+
+	bs.RequestTimeout = *requestTimeout
+	bs.GzipMinSize = *gzipMinSize
+	bs.MaxBodyBytes = *maxBodyBytes
+
+	if *rateLimit > 0 {
+		bs.RateLimiter = NewRateLimiter(*rateLimit, *rateBurst)
+	}
+
+	if *idempotencyTTL > 0 {
+		bs.Idempotency = NewIdempotencyCache(*idempotencyTTL, *idempotencyMaxKeys)
+	}
+
+	bs.APIKeys = splitList(flagOrEnv(*apiKeys, "API_KEYS"))
+	bs.AdminKeys = splitList(flagOrEnv(*adminKeys, "ADMIN_API_KEYS"))
+	bs.EnableWipe = *enableWipe
+	if bs.EnableWipe && len(bs.AdminKeys) == 0 {
+		bs.Logger.Fatal("-enable-wipe needs -admin-keys or ADMIN_API_KEYS")
+	}
+	bs.JWTSecret = []byte(os.Getenv("JWT_SECRET"))
+	if bs.writesUnauthenticated() {
+		bs.Logger.Warn("Neither API keys nor JWT_SECRET are set, write endpoints are unauthenticated")
+	}
+
+	router := setupRouter(bs)
+
+	srv := &http.Server{
+		Addr:      addr,
+		Handler:   router,
+		TLSConfig: tlsConfig,
+	}
+	// Shutdown waits for active requests, and the event streams never finish
+	// on their own.
+	if bs.Events != nil {
+		srv.RegisterOnShutdown(bs.Events.Close)
+	}
+
+	// Catch signals before anything is listening, so one that arrives as
+	// soon as the address is taken still shuts down gracefully.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		bs.Logger.WithFields(logrus.Fields{
+			"error": err.Error(),
+			"addr":  addr,
 		}).Fatal("Error when starting the server")
 	}
+
+	bs.Logger.WithFields(logrus.Fields{
+		"addr": ln.Addr().String(),
+		"tls":  tlsConfig != nil,
+	}).Info("Server listening")
+
+	var grpcSrv *grpc.Server
+	if *grpcAddr != "" {
+		grpcLn, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			bs.Logger.WithFields(logrus.Fields{
+				"error": err.Error(),
+				"addr":  *grpcAddr,
+			}).Fatal("Error when starting the gRPC server")
+		}
+
+		grpcSrv = newGRPCServer(bs, tlsConfig)
+
+		bs.Logger.WithFields(logrus.Fields{
+			"addr": grpcLn.Addr().String(),
+			"tls":  tlsConfig != nil,
+		}).Info("gRPC server listening")
+
+		go func() {
+			if err := grpcSrv.Serve(grpcLn); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+				bs.Logger.WithFields(logrus.Fields{
+					"error": err.Error(),
+				}).Fatal("Error when serving gRPC")
+			}
+		}()
+	}
+
+	if bs.RateLimiter != nil {
+		go bs.RateLimiter.cleanupLoop(ctx)
+	}
+
+	if bs.Idempotency != nil {
+		go bs.Idempotency.cleanupLoop(ctx)
+	}
+
+	go func() {
+		serve := srv.Serve
+		if tlsConfig != nil {
+			// The key pair is already in TLSConfig, so no file names are needed.
+			serve = func(ln net.Listener) error { return srv.ServeTLS(ln, "", "") }
+		}
+
+		if err := serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			bs.Logger.WithFields(logrus.Fields{
+				"error": err.Error(),
+			}).Fatal("Error when serving HTTP")
+		}
+	}()
+
+	bs.ready.Store(true)
+
+	<-ctx.Done()
+	stop()
+	bs.ready.Store(false)
+
+	bs.Logger.WithFields(logrus.Fields{
+		"timeout": shutdownTimeout.String(),
+	}).Info("Shutting down the server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+
+	if grpcSrv != nil {
+		stopGRPC(shutdownCtx, grpcSrv)
+	}
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		bs.Logger.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Error("Error when shutting down the server")
+		return
+	}
+
+	bs.Logger.Info("Server stopped")
 }
@@ -0,0 +1,756 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	// runMainEnv makes the test binary stand in for the server binary; see
+	// startMain.
+	if os.Getenv(runMainEnv) == "1" {
+		main()
+		os.Exit(0)
+	}
+
+	gin.SetMode(gin.TestMode)
+	registerValidation()
+
+	os.Exit(m.Run())
+}
+
+// newTestService returns a ready service over an empty in-memory store that
+// logs nowhere.
+func newTestService(t *testing.T) *BookService {
+	t.Helper()
+
+	return newStoreService(t, NewMemoryStore())
+}
+
+// newStoreService is newTestService over store.
+func newStoreService(t *testing.T, store BookStore) *BookService {
+	t.Helper()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	bs := newBookService(store, logger)
+	bs.ready.Store(true)
+
+	return bs
+}
+
+// do sends a request to h and returns the recorded response. A non-empty
+// body is sent as JSON; header holds name, value pairs.
+func do(t *testing.T, h http.Handler, method, path, body string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+
+	req := httptest.NewRequest(method, path, r)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	return w
+}
+
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+
+	var v T
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+
+	return v
+}
+
+// errorCode returns the code of an error envelope.
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	return decode[struct {
+		Error APIError `json:"error"`
+	}](t, w).Error.Code
+}
+
+// postBook creates a book from body and fails the test unless it is created.
+func postBook(t *testing.T, h http.Handler, body string) Book {
+	t.Helper()
+
+	w := do(t, h, http.MethodPost, "/v1/book", body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /v1/book %s: status %d, body %s", body, w.Code, w.Body)
+	}
+
+	return decode[Book](t, w)
+}
+
+func TestRoutes(t *testing.T) {
+	router := setupRouter(newTestService(t))
+
+	book := postBook(t, router, `{"name":"The Hobbit","author":"Tolkien"}`)
+	path := "/v1/book/" + book.ID
+
+	w := do(t, router, http.MethodGet, path, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s: status %d", path, w.Code)
+	}
+	if got := decode[Book](t, w); got.Name != "The Hobbit" || got.Author != "Tolkien" {
+		t.Errorf("GET %s = %+v", path, got)
+	}
+
+	w = do(t, router, http.MethodGet, "/v1/book", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /v1/book: status %d", w.Code)
+	}
+	if page := decode[BookPage](t, w); page.Total != 1 || len(page.Data) != 1 || page.Data[0].ID != book.ID {
+		t.Errorf("GET /v1/book = %+v", page)
+	}
+
+	w = do(t, router, http.MethodPut, path, `{"name":"The Hobbit","author":"J.R.R. Tolkien"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT %s: status %d, body %s", path, w.Code, w.Body)
+	}
+	if got := decode[Book](t, w); got.Author != "J.R.R. Tolkien" || got.Version != 2 {
+		t.Errorf("PUT %s = %+v", path, got)
+	}
+
+	w = do(t, router, http.MethodDelete, path, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("DELETE %s: status %d", path, w.Code)
+	}
+
+	w = do(t, router, http.MethodGet, path, "")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET %s after delete: status %d, want 404", path, w.Code)
+	}
+}
+
+func TestRoutesErrors(t *testing.T) {
+	router := setupRouter(newTestService(t))
+	book := postBook(t, router, `{"name":"Dune","author":"Herbert"}`)
+
+	t.Run("bad JSON", func(t *testing.T) {
+		w := do(t, router, http.MethodPost, "/v1/book", `{"name":`)
+		if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeInvalidJSON {
+			t.Errorf("status %d, body %s", w.Code, w.Body)
+		}
+	})
+
+	t.Run("missing record", func(t *testing.T) {
+		for _, method := range []string{http.MethodGet, http.MethodPatch, http.MethodDelete} {
+			body := ""
+			if method == http.MethodPatch {
+				body = `{"author":"x"}`
+			}
+
+			w := do(t, router, method, "/v1/book/00000000-0000-0000-0000-000000000000", body)
+			if w.Code != http.StatusNotFound || errorCode(t, w) != CodeNotFound {
+				t.Errorf("%s: status %d, body %s", method, w.Code, w.Body)
+			}
+		}
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		w := do(t, router, http.MethodPost, "/v1/book", `{"id":"`+book.ID+`","name":"Dune","author":"Herbert"}`)
+		if w.Code != http.StatusConflict || errorCode(t, w) != CodeConflict {
+			t.Errorf("status %d, body %s", w.Code, w.Body)
+		}
+	})
+}
+
+func TestHeadBookMetadata(t *testing.T) {
+	router := setupRouter(newTestService(t))
+	book := postBook(t, router, `{"name":"Emma","author":"Austen"}`)
+	deleted := postBook(t, router, `{"name":"Lady Susan","author":"Austen"}`)
+	do(t, router, http.MethodDelete, "/v1/book/"+deleted.ID, "")
+
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+
+	head := func(path string, header ...string) *http.Response {
+		req, _ := http.NewRequest(http.MethodHead, srv.URL+path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if len(body) != 0 {
+			t.Errorf("HEAD %s: %d body bytes, want none", path, len(body))
+		}
+		return resp
+	}
+
+	for _, path := range []string{"/v1/book/" + book.ID, "/book/" + book.ID} {
+		resp := head(path)
+		if resp.StatusCode != http.StatusOK || resp.ContentLength <= 0 || resp.Header.Get("ETag") == "" {
+			t.Errorf("HEAD %s: status %d, Content-Length %d, ETag %q", path, resp.StatusCode, resp.ContentLength, resp.Header.Get("ETag"))
+		}
+		if resp := head(path, "If-None-Match", resp.Header.Get("ETag")); resp.StatusCode != http.StatusNotModified {
+			t.Errorf("HEAD %s with its ETag: status %d, want 304", path, resp.StatusCode)
+		}
+	}
+
+	for _, id := range []string{deleted.ID, "00000000-0000-0000-0000-000000000000"} {
+		if resp := head("/v1/book/" + id); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("HEAD %s: status %d, want 404", id, resp.StatusCode)
+		}
+	}
+}
+
+const seedID = "11111111-1111-1111-1111-111111111111"
+
+// newSeededRouter returns a router over a store holding one book at seedID.
+func newSeededRouter(t *testing.T) http.Handler {
+	t.Helper()
+
+	router := setupRouter(newTestService(t))
+	postBook(t, router, `{"id":"`+seedID+`","name":"Emma","author":"Austen"}`)
+
+	return router
+}
+
+func TestHandlers(t *testing.T) {
+	const (
+		seedPath    = "/v1/book/" + seedID
+		missingPath = "/v1/book/22222222-2222-2222-2222-222222222222"
+	)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"create", http.MethodPost, "/v1/book", `{"name":"Persuasion","author":"Austen"}`, http.StatusCreated, ""},
+		{"create bad JSON", http.MethodPost, "/v1/book", `{"name":"Persuasion",`, http.StatusBadRequest, CodeInvalidJSON},
+		{"create without name", http.MethodPost, "/v1/book", `{"author":"Austen"}`, http.StatusBadRequest, CodeValidationFailed},
+		{"create with invalid ID", http.MethodPost, "/v1/book", `{"id":"abc","name":"Persuasion","author":"Austen"}`, http.StatusBadRequest, CodeValidationFailed},
+		{"create duplicate", http.MethodPost, "/v1/book", `{"id":"` + seedID + `","name":"Emma","author":"Austen"}`, http.StatusConflict, CodeConflict},
+
+		{"get", http.MethodGet, seedPath, "", http.StatusOK, ""},
+		{"get missing", http.MethodGet, missingPath, "", http.StatusNotFound, CodeNotFound},
+
+		{"list", http.MethodGet, "/v1/book", "", http.StatusOK, ""},
+		{"list bad limit", http.MethodGet, "/v1/book?limit=-1", "", http.StatusBadRequest, CodeBadRequest},
+
+		{"update", http.MethodPut, seedPath, `{"name":"Emma","author":"Jane Austen"}`, http.StatusOK, ""},
+		{"update bad JSON", http.MethodPut, seedPath, `[`, http.StatusBadRequest, CodeInvalidJSON},
+		{"update without author", http.MethodPut, seedPath, `{"name":"Emma"}`, http.StatusBadRequest, CodeValidationFailed},
+		{"update stale version", http.MethodPut, seedPath, `{"name":"Emma","author":"Austen","version":7}`, http.StatusConflict, CodeConflict},
+
+		{"patch", http.MethodPatch, seedPath, `{"author":"Jane Austen"}`, http.StatusOK, ""},
+		{"patch empty name", http.MethodPatch, seedPath, `{"name":""}`, http.StatusBadRequest, CodeValidationFailed},
+		{"patch missing", http.MethodPatch, missingPath, `{"author":"Jane Austen"}`, http.StatusNotFound, CodeNotFound},
+
+		{"delete", http.MethodDelete, seedPath, "", http.StatusOK, ""},
+		{"delete missing", http.MethodDelete, missingPath, "", http.StatusNotFound, CodeNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(t, newSeededRouter(t), tt.method, tt.path, tt.body)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d; body %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantCode != "" {
+				if code := errorCode(t, w); code != tt.wantCode {
+					t.Errorf("error code %q, want %q", code, tt.wantCode)
+				}
+			}
+		})
+	}
+}
+
+func TestPutUpsert(t *testing.T) {
+	router := newSeededRouter(t)
+	const newID = "33333333-3333-3333-3333-333333333333"
+
+	w := do(t, router, http.MethodPut, "/v1/book/"+newID, `{"id":"`+seedID+`","name":"Sanditon","author":"Austen"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("PUT on a missing ID: status %d, want 201; body %s", w.Code, w.Body)
+	}
+	if got := decode[Book](t, w); got.ID != newID {
+		t.Errorf("created book has ID %q, want the path ID %q", got.ID, newID)
+	}
+	if loc := w.Header().Get("Location"); loc != bookPathPrefix+newID {
+		t.Errorf("Location %q", loc)
+	}
+
+	w = do(t, router, http.MethodPut, "/v1/book/"+newID, `{"name":"Sanditon","author":"Jane Austen"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT on an existing ID: status %d, want 200", w.Code)
+	}
+	if got := decode[Book](t, w); got.Author != "Jane Austen" || got.Version != 2 {
+		t.Errorf("replaced book = %+v", got)
+	}
+}
+
+func TestPutSoftDeleted(t *testing.T) {
+	for _, upsert := range []bool{true, false} {
+		bs := newTestService(t)
+		bs.PutUpsert = upsert
+		router := setupRouter(bs)
+
+		book := postBook(t, router, `{"name":"Emma","author":"Austen"}`)
+		path := "/v1/book/" + book.ID
+		do(t, router, http.MethodDelete, path, "")
+
+		w := do(t, router, http.MethodPut, path, `{"name":"Emma","author":"Jane Austen"}`)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("upsert %v: PUT on a soft-deleted book: status %d, want 404", upsert, w.Code)
+		}
+
+		stored, err := bs.Store.GetByID(context.Background(), book.ID)
+		if err != nil || !stored.deleted() || stored.Author != "Austen" {
+			t.Errorf("upsert %v: stored book = %+v, %v; want it still deleted and unchanged", upsert, stored, err)
+		}
+
+		if w := do(t, router, http.MethodPost, path+"/restore", ""); w.Code != http.StatusOK {
+			t.Fatalf("restore: status %d", w.Code)
+		}
+		if w := do(t, router, http.MethodPut, path, `{"name":"Emma","author":"Jane Austen"}`); w.Code != http.StatusOK {
+			t.Errorf("upsert %v: PUT after restore: status %d, want 200", upsert, w.Code)
+		}
+	}
+}
+
+func TestDeleteAllBooks(t *testing.T) {
+	bs := newTestService(t)
+	bs.EnableWipe = true
+	bs.AdminKeys = []string{"admin-secret"}
+	router := setupRouter(bs)
+
+	for _, name := range []string{"Emma", "Persuasion", "Sanditon"} {
+		postBook(t, router, `{"name":"`+name+`","author":"Austen"}`)
+	}
+	deleted := postBook(t, router, `{"name":"Lady Susan","author":"Austen"}`)
+	do(t, router, http.MethodDelete, "/v1/book/"+deleted.ID, "")
+
+	if w := do(t, router, http.MethodDelete, "/v1/book?confirm=true", "", adminKeyHeader, "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong admin key: status %d, want 401", w.Code)
+	}
+	if w := do(t, router, http.MethodDelete, "/v1/book?confirm=true", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("no admin key: status %d, want 401", w.Code)
+	}
+	if w := do(t, router, http.MethodDelete, "/v1/book", "", adminKeyHeader, "admin-secret"); w.Code != http.StatusBadRequest {
+		t.Errorf("without confirm: status %d, want 400", w.Code)
+	}
+
+	w := do(t, router, http.MethodDelete, "/v1/book?confirm=true", "", adminKeyHeader, "admin-secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", w.Code, w.Body)
+	}
+	if got := decode[map[string]int](t, w)["deleted"]; got != 4 {
+		t.Errorf("deleted = %d, want 4 including the soft-deleted book", got)
+	}
+
+	if books, _ := bs.Store.GetAll(context.Background()); len(books) != 0 {
+		t.Errorf("%d books left after the wipe", len(books))
+	}
+}
+
+func TestDeleteAllBooksOnEveryBackend(t *testing.T) {
+	for name, store := range storeBackends(t) {
+		bs := newStoreService(t, store)
+		bs.EnableWipe = true
+		bs.AdminKeys = []string{"admin-secret"}
+		bs.APIKeys = []string{"api-key"}
+		router := setupRouter(bs)
+
+		for _, body := range []string{`{"name":"Emma","author":"Austen"}`, `{"name":"Dune","author":"Herbert"}`} {
+			do(t, router, http.MethodPost, "/v1/book", body, apiKeyHeader, "api-key")
+		}
+
+		for _, query := range []string{"", "?confirm=1", "?confirm=yes"} {
+			w := do(t, router, http.MethodDelete, "/v1/book"+query, "", apiKeyHeader, "api-key", adminKeyHeader, "admin-secret")
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "confirm=true") {
+				t.Errorf("%s: DELETE /v1/book%s: status %d, body %s; want 400", name, query, w.Code, w.Body)
+			}
+		}
+		if w := do(t, router, http.MethodDelete, "/v1/book?confirm=true", "", adminKeyHeader, "admin-secret"); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: admin key without an API key: status %d, want 401", name, w.Code)
+		}
+		if n, _ := store.Count(context.Background()); n != 2 {
+			t.Fatalf("%s: %d books left after refused wipes, want 2", name, n)
+		}
+
+		w := do(t, router, http.MethodDelete, "/v1/book?confirm=true", "", apiKeyHeader, "api-key", adminKeyHeader, "admin-secret")
+		if w.Code != http.StatusOK || w.Body.String() != `{"deleted":2}` {
+			t.Errorf("%s: confirmed wipe: status %d, body %s", name, w.Code, w.Body)
+		}
+		if books, _ := store.GetAll(context.Background()); len(books) != 0 {
+			t.Errorf("%s: %d books left after the wipe", name, len(books))
+		}
+	}
+}
+
+func TestDeleteAllBooksNotMounted(t *testing.T) {
+	for name, configure := range map[string]func(*BookService){
+		"without -enable-wipe": func(bs *BookService) { bs.AdminKeys = []string{"admin-secret"} },
+		"without admin keys":   func(bs *BookService) { bs.EnableWipe = true },
+	} {
+		bs := newTestService(t)
+		configure(bs)
+		router := setupRouter(bs)
+		postBook(t, router, `{"name":"Emma","author":"Austen"}`)
+
+		w := do(t, router, http.MethodDelete, "/v1/book?confirm=true", "", adminKeyHeader, "admin-secret")
+		if w.Code != http.StatusNotFound && w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s: status %d, want the route missing", name, w.Code)
+		}
+		if n, _ := bs.Store.Count(context.Background()); n != 1 {
+			t.Errorf("%s: %d books left, want 1", name, n)
+		}
+	}
+}
+
+func TestCreateDuplicate(t *testing.T) {
+	router := setupRouter(newTestService(t))
+	body := `{"id":"` + seedID + `","name":"Emma","author":"Austen"}`
+
+	postBook(t, router, body)
+
+	w := do(t, router, http.MethodPost, "/v1/book", `{"id":"`+seedID+`","name":"Persuasion","author":"Austen"}`)
+	if w.Code != http.StatusConflict || errorCode(t, w) != CodeConflict {
+		t.Fatalf("second POST: status %d, body %s; want 409 %s", w.Code, w.Body, CodeConflict)
+	}
+	if !strings.Contains(w.Body.String(), "Book with this ID already exists") {
+		t.Errorf("body %s", w.Body)
+	}
+
+	w = do(t, router, http.MethodGet, "/v1/book/"+seedID, "")
+	if got := decode[Book](t, w); got.Name != "Emma" {
+		t.Errorf("stored book = %+v, want the first one kept", got)
+	}
+}
+
+func TestPatchBook(t *testing.T) {
+	router := newSeededRouter(t)
+	path := "/v1/book/" + seedID
+
+	w := do(t, router, http.MethodPatch, path, `{"author":"Jane Austen"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", w.Code, w.Body)
+	}
+	if got := decode[Book](t, w); got.Name != "Emma" || got.Author != "Jane Austen" || got.Version != 2 {
+		t.Errorf("patched book = %+v, want only the author changed", got)
+	}
+
+	w = do(t, router, http.MethodGet, path, "")
+	if got := decode[Book](t, w); got.Name != "Emma" || got.Author != "Jane Austen" {
+		t.Errorf("stored book = %+v", got)
+	}
+}
+
+func TestCreateGeneratesID(t *testing.T) {
+	bs := newTestService(t)
+	router := setupRouter(bs)
+
+	book := postBook(t, router, `{"name":"Emma","author":"Austen"}`)
+	if _, err := uuid.Parse(book.ID); err != nil {
+		t.Fatalf("generated ID %q: %v", book.ID, err)
+	}
+
+	stored, err := bs.Store.GetByID(context.Background(), book.ID)
+	if err != nil || stored.Name != "Emma" {
+		t.Errorf("stored under %s: %+v, %v", book.ID, stored, err)
+	}
+
+	if other := postBook(t, router, `{"name":"Emma","author":"Austen"}`); other.ID == book.ID {
+		t.Errorf("two creates were given the same ID %s", book.ID)
+	}
+}
+
+func TestCreateLocation(t *testing.T) {
+	router := setupRouter(newTestService(t))
+
+	for _, tt := range []struct {
+		name, body string
+		wantID     string
+	}{
+		{"generated ID", `{"name":"Emma","author":"Austen"}`, ""},
+		{"client ID", `{"id":"` + seedID + `","name":"Emma","author":"Austen"}`, seedID},
+	} {
+		w := do(t, router, http.MethodPost, "/v1/book", tt.body)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("%s: status %d, body %s", tt.name, w.Code, w.Body)
+		}
+
+		book := decode[Book](t, w)
+		if tt.wantID != "" && book.ID != tt.wantID {
+			t.Errorf("%s: ID %q, want %q", tt.name, book.ID, tt.wantID)
+		}
+		if loc := w.Header().Get("Location"); loc != "/v1/book/"+book.ID {
+			t.Errorf("%s: Location %q", tt.name, loc)
+		}
+		if w := do(t, router, http.MethodGet, w.Header().Get("Location"), ""); w.Code != http.StatusOK {
+			t.Errorf("%s: GET Location: status %d", tt.name, w.Code)
+		}
+	}
+}
+
+func TestCreateLocationIsCanonical(t *testing.T) {
+	router := setupRouter(newTestService(t))
+
+	for _, tt := range []struct{ method, path string }{
+		{http.MethodPost, "/book"},
+		{http.MethodPut, "/v1/book/" + seedID},
+	} {
+		w := do(t, router, tt.method, tt.path, `{"name":"Emma","author":"Austen"}`)
+		book := decode[Book](t, w)
+		if w.Code != http.StatusCreated || w.Header().Get("Location") != "/v1/book/"+book.ID {
+			t.Errorf("%s %s: status %d, Location %q; want 201 at /v1/book/%s", tt.method, tt.path, w.Code, w.Header().Get("Location"), book.ID)
+			continue
+		}
+
+		got := do(t, router, http.MethodGet, w.Header().Get("Location"), "")
+		if got.Code != http.StatusOK || got.Body.String() != w.Body.String() {
+			t.Errorf("%s %s: GET Location %s: status %d, body %s; want the created book", tt.method, tt.path, w.Header().Get("Location"), got.Code, got.Body)
+		}
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	bs := newTestService(t)
+	clock := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	bs.Now = func() time.Time { return clock }
+	router := setupRouter(bs)
+
+	book := postBook(t, router, `{"name":"Emma","author":"Austen","created_at":"2000-01-01T00:00:00Z","updated_at":"2000-01-01T00:00:00Z"}`)
+	if !book.CreatedAt.Equal(clock) || !book.UpdatedAt.Equal(clock) {
+		t.Fatalf("created book has timestamps %v, %v; want both %v", book.CreatedAt, book.UpdatedAt, clock)
+	}
+	created := clock
+	path := "/v1/book/" + book.ID
+
+	for _, req := range []struct{ method, body string }{
+		{http.MethodPut, `{"name":"Emma","author":"Jane Austen","created_at":"2000-01-01T00:00:00Z"}`},
+		{http.MethodPatch, `{"author":"J. Austen"}`},
+	} {
+		clock = clock.Add(time.Hour)
+
+		w := do(t, router, req.method, path, req.body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d, body %s", req.method, w.Code, w.Body)
+		}
+		if got := decode[Book](t, w); !got.CreatedAt.Equal(created) || !got.UpdatedAt.Equal(clock) {
+			t.Errorf("%s: timestamps %v, %v; want %v, %v", req.method, got.CreatedAt, got.UpdatedAt, created, clock)
+		}
+	}
+
+	if w := do(t, router, http.MethodGet, path, ""); !strings.Contains(w.Body.String(), `"created_at":"2024-05-01T12:00:00Z"`) {
+		t.Errorf("created_at is not RFC 3339 in %s", w.Body)
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	bs := newTestService(t)
+	router := setupRouter(bs)
+	book := postBook(t, router, `{"name":"Emma","author":"Austen"}`)
+	postBook(t, router, `{"name":"Persuasion","author":"Austen"}`)
+	path := "/v1/book/" + book.ID
+
+	if w := do(t, router, http.MethodDelete, path, ""); w.Code != http.StatusOK {
+		t.Fatalf("DELETE: status %d", w.Code)
+	}
+	if w := do(t, router, http.MethodGet, path, ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET after delete: status %d, want 404", w.Code)
+	}
+	if w := do(t, router, http.MethodDelete, path, ""); w.Code != http.StatusNotFound {
+		t.Errorf("second DELETE: status %d, want 404", w.Code)
+	}
+	if stored, err := bs.Store.GetByID(context.Background(), book.ID); err != nil || !stored.deleted() {
+		t.Errorf("stored book = %+v, %v; want it kept with deleted_at set", stored, err)
+	}
+
+	if page := decode[BookPage](t, do(t, router, http.MethodGet, "/v1/book", "")); page.Total != 1 {
+		t.Errorf("list: total %d, want the deleted book hidden", page.Total)
+	}
+	page := decode[BookPage](t, do(t, router, http.MethodGet, "/v1/book?include_deleted=true", ""))
+	if page.Total != 2 || page.Data[0].ID != book.ID && page.Data[1].ID != book.ID {
+		t.Errorf("list with include_deleted: %+v", page)
+	}
+	w := do(t, router, http.MethodGet, path+"?include_deleted=true", "")
+	if got := decode[Book](t, w); w.Code != http.StatusOK || got.DeletedAt == nil {
+		t.Errorf("GET with include_deleted: status %d, body %s", w.Code, w.Body)
+	}
+
+	w = do(t, router, http.MethodPost, path+"/restore", "")
+	if got := decode[Book](t, w); w.Code != http.StatusOK || got.DeletedAt != nil {
+		t.Errorf("restore: status %d, body %s", w.Code, w.Body)
+	}
+	if w := do(t, router, http.MethodGet, path, ""); w.Code != http.StatusOK {
+		t.Errorf("GET after restore: status %d", w.Code)
+	}
+	if w := do(t, router, http.MethodPost, "/v1/book/"+seedID+"/restore", ""); w.Code != http.StatusNotFound {
+		t.Errorf("restore of a missing book: status %d, want 404", w.Code)
+	}
+}
+
+func TestRestoreOnEveryBackend(t *testing.T) {
+	for name, store := range storeBackends(t) {
+		bs := newStoreService(t, store)
+		router := setupRouter(bs)
+		book := postBook(t, router, `{"name":"Emma","author":"Austen"}`)
+		path := "/v1/book/" + book.ID
+
+		do(t, router, http.MethodDelete, path, "")
+		if stored, err := store.GetByID(context.Background(), book.ID); err != nil || !stored.deleted() {
+			t.Errorf("%s: stored after delete = %+v, %v; want deleted_at kept", name, stored, err)
+		}
+		if page := decode[BookPage](t, do(t, router, http.MethodGet, "/v1/book", "")); page.Total != 0 {
+			t.Errorf("%s: list after delete has %d books", name, page.Total)
+		}
+
+		w := do(t, router, http.MethodPost, path+"/restore", "")
+		restored := decode[Book](t, w)
+		if w.Code != http.StatusOK || restored.DeletedAt != nil || restored.Version != book.Version+2 {
+			t.Errorf("%s: restore: status %d, body %s", name, w.Code, w.Body)
+		}
+
+		events, unsubscribe := bs.Events.Subscribe()
+		w = do(t, router, http.MethodPost, path+"/restore", "")
+		unsubscribe()
+		if again := decode[Book](t, w); w.Code != http.StatusOK || again.Version != restored.Version {
+			t.Errorf("%s: restoring a live book: status %d, version %d", name, w.Code, again.Version)
+		}
+		if event, ok := <-events; ok {
+			t.Errorf("%s: restoring a live book published %+v", name, event)
+		}
+
+		page := decode[BookPage](t, do(t, router, http.MethodGet, "/v1/book", ""))
+		if page.Total != 1 || page.Data[0].ID != book.ID || page.Data[0].DeletedAt != nil {
+			t.Errorf("%s: list after restore: %+v", name, page)
+		}
+	}
+}
+
+func TestTimestampsAreServerControlled(t *testing.T) {
+	for name, store := range storeBackends(t) {
+		router := setupRouter(newStoreService(t, store))
+
+		before := time.Now().UTC()
+		book := postBook(t, router, `{"name":"Emma","author":"Austen","created_at":"2000-01-01T00:00:00Z"}`)
+		if book.CreatedAt.Location() != time.UTC || book.CreatedAt.Before(before) || !book.UpdatedAt.Equal(book.CreatedAt) {
+			t.Errorf("%s: created with timestamps %v, %v", name, book.CreatedAt, book.UpdatedAt)
+		}
+
+		w := do(t, router, http.MethodPatch, "/v1/book/"+book.ID, `{"author":"Jane Austen"}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: PATCH: status %d, body %s", name, w.Code, w.Body)
+		}
+
+		got := decode[Book](t, do(t, router, http.MethodGet, "/v1/book/"+book.ID, ""))
+		if !got.CreatedAt.Equal(book.CreatedAt) || got.UpdatedAt.Before(got.CreatedAt) {
+			t.Errorf("%s: stored after update with timestamps %v, %v; created %v", name, got.CreatedAt, got.UpdatedAt, book.CreatedAt)
+		}
+	}
+}
+
+func TestPutMissingWithoutUpsert(t *testing.T) {
+	bs := newTestService(t)
+	bs.PutUpsert = false
+	router := setupRouter(bs)
+
+	w := do(t, router, http.MethodPut, "/v1/book/"+seedID, `{"name":"Emma","author":"Austen"}`)
+	if w.Code != http.StatusNotFound || errorCode(t, w) != CodeNotFound {
+		t.Errorf("status %d, body %s; want 404", w.Code, w.Body)
+	}
+	if n, _ := bs.Store.Count(context.Background()); n != 0 {
+		t.Errorf("%d books stored by a PUT on a missing ID", n)
+	}
+}
+
+// racedStore loses every Update to a concurrent writer.
+type racedStore struct{ BookStore }
+
+func (racedStore) Update(context.Context, string, Book) error { return ErrVersionConflict }
+
+func TestVersionedUpdate(t *testing.T) {
+	bs := newTestService(t)
+	router := setupRouter(bs)
+	book := postBook(t, router, `{"name":"Emma","author":"Austen","version":7}`)
+	if book.Version != 1 {
+		t.Fatalf("created with version %d, want 1", book.Version)
+	}
+	path := "/v1/book/" + book.ID
+
+	w := do(t, router, http.MethodPut, path, `{"name":"Emma","author":"Jane Austen","version":1}`)
+	if got := decode[Book](t, w); w.Code != http.StatusOK || got.Version != 2 {
+		t.Fatalf("update with the current version: status %d, body %s", w.Code, w.Body)
+	}
+
+	w = do(t, router, http.MethodPut, path, `{"name":"Emma","author":"J. Austen","version":1}`)
+	conflict := decode[struct {
+		Error struct {
+			Code    string         `json:"code"`
+			Details map[string]int `json:"details"`
+		} `json:"error"`
+	}](t, w).Error
+	if w.Code != http.StatusConflict || conflict.Code != CodeConflict || conflict.Details["current_version"] != 2 {
+		t.Errorf("stale update: status %d, body %s; want 409 with current_version 2", w.Code, w.Body)
+	}
+	if got := decode[Book](t, do(t, router, http.MethodGet, path, "")); got.Author != "Jane Austen" || got.Version != 2 {
+		t.Errorf("after the rejected update: %+v", got)
+	}
+
+	bs.Store = racedStore{bs.Store}
+	w = do(t, setupRouter(bs), http.MethodPut, path, `{"name":"Emma","author":"J. Austen","version":2}`)
+	if w.Code != http.StatusConflict || errorCode(t, w) != CodeConflict {
+		t.Errorf("update lost to a concurrent writer: status %d, body %s; want 409", w.Code, w.Body)
+	}
+}
+
+func TestPutPathIDWins(t *testing.T) {
+	const otherID = "99999999-9999-9999-9999-999999999999"
+
+	for _, path := range []string{"/v1/book/" + seedID, "/v1/book/22222222-2222-2222-2222-222222222222"} {
+		bs := newTestService(t)
+		router := setupRouter(bs)
+		postBook(t, router, `{"id":"`+seedID+`","name":"Emma","author":"Austen"}`)
+
+		w := do(t, router, http.MethodPut, path, `{"id":"`+otherID+`","name":"Persuasion","author":"Austen"}`)
+		id := strings.TrimPrefix(path, "/v1/book/")
+		if got := decode[Book](t, w); w.Code >= 300 || got.ID != id {
+			t.Errorf("PUT %s: status %d, body %s; want the path ID", path, w.Code, w.Body)
+		}
+
+		if stored, err := bs.Store.GetByID(context.Background(), id); err != nil || stored.ID != id || stored.Name != "Persuasion" {
+			t.Errorf("PUT %s: stored %+v, %v", path, stored, err)
+		}
+		if _, err := bs.Store.GetByID(context.Background(), otherID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("PUT %s: a book was stored under the body ID (%v)", path, err)
+		}
+	}
+}
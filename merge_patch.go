@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+const mimeMergePatch = "application/merge-patch+json"
+
+// decodeMergePatch reads an RFC 7396 merge patch into a BookPatch. Keys set
+// to null become empty strings, so the field is cleared when the patch is
+// applied; absent keys stay nil and leave the field untouched. The patched
+// book must be validated afterwards because nothing here checks values.
+func decodeMergePatch(c *gin.Context) (BookPatch, error) {
+	var patch BookPatch
+
+	var fields map[string]json.RawMessage
+	dec := json.NewDecoder(c.Request.Body)
+	if err := dec.Decode(&fields); err != nil {
+		return patch, err
+	}
+	if err := expectEOF(dec); err != nil {
+		return patch, err
+	}
+	if fields == nil {
+		return patch, errors.New("merge patch must be a JSON object")
+	}
+
+	targets := map[string]**string{
+		"name":   &patch.Name,
+		"author": &patch.Author,
+		"isbn":   &patch.ISBN,
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		target, ok := targets[key]
+		if !ok {
+			return patch, &unknownFieldError{Field: key}
+		}
+
+		var value string
+		if raw := fields[key]; string(raw) != "null" {
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return patch, err
+			}
+		}
+
+		*target = &value
+	}
+
+	return patch, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	router := setupRouter(newTestService(t))
+	book := postBook(t, router, `{"name":"Emma","author":"Austen","isbn":"080442957X"}`)
+	path := "/v1/book/" + book.ID
+	patch := func(contentType, body string) *httptest.ResponseRecorder {
+		return do(t, router, http.MethodPatch, path, body, "Content-Type", contentType)
+	}
+
+	w := patch(mimeMergePatch, `{"isbn":null,"name":"Emma (annotated)"}`)
+	if got := decode[Book](t, w); w.Code != http.StatusOK || got.ISBN != "" || got.Name != "Emma (annotated)" || got.Author != "Austen" {
+		t.Fatalf("null isbn: status %d, body %s; want it cleared and author untouched", w.Code, w.Body)
+	}
+
+	// Author is required, so clearing it fails validation instead.
+	w = patch(mimeMergePatch, `{"author":null}`)
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeValidationFailed || !strings.Contains(w.Body.String(), "author") {
+		t.Errorf("null author: status %d, body %s; want 400 naming author", w.Code, w.Body)
+	}
+
+	// In a plain JSON patch null means "not sent".
+	w = patch("application/json", `{"author":null,"name":"Emma"}`)
+	if got := decode[Book](t, w); w.Code != http.StatusOK || got.Author != "Austen" || got.Name != "Emma" {
+		t.Errorf("JSON patch with null author: status %d, body %s; want author untouched", w.Code, w.Body)
+	}
+
+	for contentType, body := range map[string]string{
+		mimeMergePatch: `["not","an","object"]`,
+		"text/plain":   `{"name":"Emma"}`,
+	} {
+		w := patch(contentType, body)
+		want := http.StatusBadRequest
+		if contentType == "text/plain" {
+			want = http.StatusUnsupportedMediaType
+		}
+		if w.Code != want {
+			t.Errorf("%s %s: status %d, want %d", contentType, body, w.Code, want)
+		}
+	}
+
+	if got := decode[Book](t, do(t, router, http.MethodGet, path, "")); got.Author != "Austen" || got.ISBN != "" {
+		t.Errorf("stored %+v", got)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, h http.Handler) string {
+	t.Helper()
+
+	w := do(t, h, http.MethodGet, metricsPath, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s: status %d", metricsPath, w.Code)
+	}
+
+	return w.Body.String()
+}
+
+func TestMetricsCountRequestsAndBooks(t *testing.T) {
+	router := setupRouter(newTestService(t))
+
+	kept := postBook(t, router, `{"name":"Kept","author":"A"}`)
+	deleted := postBook(t, router, `{"name":"Deleted","author":"A"}`)
+	do(t, router, http.MethodDelete, "/v1/book/"+deleted.ID, "")
+	do(t, router, http.MethodGet, "/v1/book/"+kept.ID, "")
+
+	body := scrape(t, router)
+
+	for _, want := range []string{
+		`http_requests_total{method="POST",path="/v1/book",status="201"} 2`,
+		`http_requests_total{method="GET",path="/v1/book/:id",status="200"} 1`,
+		`books_stored 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics has no %q", want)
+		}
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	router := setupRouter(newTestService(t))
+
+	do(t, router, http.MethodGet, "/v1/book", "")
+	do(t, router, http.MethodGet, "/v1/book/"+seedID, "")
+	do(t, router, http.MethodGet, "/no/such/route", "")
+	scrape(t, router)
+
+	body := scrape(t, router)
+
+	for _, want := range []string{
+		`http_requests_total{method="GET",path="/v1/book",status="200"} 1`,
+		`http_requests_total{method="GET",path="/v1/book/:id",status="404"} 1`,
+		`http_requests_total{method="GET",path="unmatched",status="404"} 1`,
+		`http_request_duration_seconds_bucket{method="GET",path="/v1/book",le="+Inf"} 1`,
+		`http_requests_in_flight{`,
+		`go_goroutines `,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics has no %q", want)
+		}
+	}
+
+	if strings.Contains(body, `path="`+metricsPath+`"`) {
+		t.Error("scrapes of /metrics are counted")
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	requestIDKey    = "request_id"
+
+	defaultMaxBodyBytes = 1 << 20
+)
+
+func requestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if id == "" {
+			id = uuid.NewString()
+		}
+
+		c.Set(requestIDKey, id)
+		c.Header(requestIDHeader, id)
+
+		c.Next()
+	}
+}
+
+// accessLog logs every request as a structured entry once it has been
+// answered, in place of gin's text logger. Server errors are logged at
+// error level and client errors at warning level.
+func accessLog(logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		status := c.Writer.Status()
+		entry := logger.WithFields(logrus.Fields{
+			"method":     c.Request.Method,
+			"path":       c.Request.URL.Path,
+			"status":     status,
+			"latency":    time.Since(start).String(),
+			"client_ip":  c.ClientIP(),
+			"request_id": c.GetString(requestIDKey),
+		})
+
+		switch {
+		case status >= http.StatusInternalServerError:
+			entry.Error("Request handled")
+		case status >= http.StatusBadRequest:
+			entry.Warn("Request handled")
+		default:
+			entry.Info("Request handled")
+		}
+	}
+}
+
+// recovery turns a panic in a handler into a 500 logged through logger with
+// its stack, in place of gin.Recovery. The panic value is never sent to the
+// client.
+func recovery(logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				// net/http uses this panic to abort the response on purpose.
+				panic(rec)
+			}
+
+			logger.WithFields(logrus.Fields{
+				"error":      fmt.Sprint(rec),
+				"method":     c.Request.Method,
+				"endpoint":   c.Request.URL.Path,
+				"request_id": c.GetString(requestIDKey),
+				"stack":      string(debug.Stack()),
+			}).Error("Panic while handling request")
+
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			respondError(c, http.StatusInternalServerError, CodeInternal, "Internal server error")
+		}()
+
+		c.Next()
+	}
+}
+
+// deprecatedRoutes marks responses from the unversioned paths as deprecated
+// and logs who is still calling them.
+func (bs *BookService) deprecatedRoutes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bs.Logger.WithFields(logrus.Fields{
+			"method":     c.Request.Method,
+			"endpoint":   c.Request.URL.Path,
+			"client_ip":  c.ClientIP(),
+			"request_id": c.GetString(requestIDKey),
+		}).Warn("Deprecated unversioned route called, use " + apiVersionPrefix + " instead")
+
+		c.Header("Deprecation", "true")
+		c.Header("Link", "<"+apiVersionPrefix+c.Request.URL.Path+`>; rel="successor-version"`)
+
+		c.Next()
+	}
+}
+
+// limitRequestBody rejects bodies over limit bytes. A declared Content-Length
+// is checked up front; otherwise the read fails once the limit is passed and
+// bindError turns that into the same 413.
+func limitRequestBody(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			respondBodyTooLarge(c, limit)
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+
+		c.Next()
+	}
+}
+
+func respondBodyTooLarge(c *gin.Context, limit int64) {
+	respondError(c, http.StatusRequestEntityTooLarge, CodePayloadTooLarge,
+		"Request body must not exceed "+strconv.FormatInt(limit, 10)+" bytes")
+}
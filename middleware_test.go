@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestRequestID(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	bs := newBookService(failingStore{NewMemoryStore()}, logger)
+	bs.ready.Store(true)
+	router := setupRouter(bs)
+
+	w := do(t, router, http.MethodGet, "/healthz", "")
+	if _, err := uuid.Parse(w.Header().Get(requestIDHeader)); err != nil {
+		t.Errorf("generated %s %q: %v", requestIDHeader, w.Header().Get(requestIDHeader), err)
+	}
+	if other := do(t, router, http.MethodGet, "/healthz", ""); other.Header().Get(requestIDHeader) == w.Header().Get(requestIDHeader) {
+		t.Error("two requests were given the same ID")
+	}
+
+	hook.Reset()
+	w = do(t, router, http.MethodPost, "/v1/book", `{"name":"Emma","author":"Austen"}`, requestIDHeader, "trace-42")
+	if got := w.Header().Get(requestIDHeader); got != "trace-42" {
+		t.Errorf("%s %q, want the one sent echoed", requestIDHeader, got)
+	}
+
+	var messages []string
+	for _, entry := range hook.AllEntries() {
+		if entry.Data["request_id"] != "trace-42" {
+			t.Errorf("%q logged with request_id %v", entry.Message, entry.Data["request_id"])
+		}
+		messages = append(messages, entry.Message)
+	}
+	if strings.Join(messages, ",") != "Error when accessing storage,Request handled" {
+		t.Errorf("logged %q", messages)
+	}
+}
+
+// brokenAuthors fails every write so the generic resource logs an error.
+type brokenAuthors struct{ Store[Author] }
+
+func (brokenAuthors) Create(context.Context, Author) error { return errTestStore }
+
+func TestRequestIDInLogs(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	bs := newBookService(NewMemoryStore(), logger)
+	bs.Authors = brokenAuthors{NewMemStore[Author]()}
+	bs.ready.Store(true)
+	router := setupRouter(bs)
+
+	w := do(t, router, http.MethodPost, "/v1/author", `{"name":"Austen"}`)
+	id := w.Header().Get(requestIDHeader)
+	if _, err := uuid.Parse(id); err != nil {
+		t.Fatalf("generated %s %q: %v", requestIDHeader, id, err)
+	}
+	do(t, router, http.MethodGet, "/book", "", requestIDHeader, "legacy-7")
+
+	var stored, deprecated any
+	for _, entry := range hook.AllEntries() {
+		switch {
+		case entry.Message == "Error when accessing storage":
+			stored = entry.Data["request_id"]
+		case strings.HasPrefix(entry.Message, "Deprecated"):
+			deprecated = entry.Data["request_id"]
+		}
+	}
+	if stored != id {
+		t.Errorf("storage error logged with request_id %v, want %s", stored, id)
+	}
+	if deprecated != "legacy-7" {
+		t.Errorf("deprecation warning logged with request_id %v, want legacy-7", deprecated)
+	}
+}
+
+func TestRequestBodyLimit(t *testing.T) {
+	bs := newTestService(t)
+	bs.MaxBodyBytes = 64
+	router := setupRouter(bs)
+	big := `{"name":"` + strings.Repeat("x", 100) + `","author":"Austen"}`
+
+	for _, tt := range []struct{ method, path string }{
+		{http.MethodPost, "/v1/book"},
+		{http.MethodPut, "/v1/book/" + seedID},
+		{http.MethodPost, "/v1/books/batch"},
+	} {
+		w := do(t, router, tt.method, tt.path, big)
+		if w.Code != http.StatusRequestEntityTooLarge || errorCode(t, w) != CodePayloadTooLarge {
+			t.Errorf("%s %s: status %d, body %s; want 413", tt.method, tt.path, w.Code, w.Body)
+		}
+	}
+
+	// Without a Content-Length the limit is only hit while decoding.
+	req := httptest.NewRequest(http.MethodPost, "/v1/book", io.MultiReader(strings.NewReader(big)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), "64 bytes") {
+		t.Errorf("streamed body: status %d, body %s; want 413 naming the limit", w.Code, w.Body)
+	}
+
+	if total := decode[BookPage](t, do(t, router, http.MethodGet, "/v1/book", "")).Total; total != 0 {
+		t.Errorf("%d books stored from oversized bodies", total)
+	}
+	if w := do(t, router, http.MethodPost, "/v1/book", `{"name":"Emma","author":"Austen"}`); w.Code != http.StatusCreated {
+		t.Errorf("body under the limit: status %d", w.Code)
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var out bytes.Buffer
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetOutput(&out)
+	bs := newBookService(NewMemoryStore(), logger)
+	router := setupRouter(bs)
+
+	do(t, router, http.MethodGet, "/v1/book?limit=5", "", requestIDHeader, "trace-1")
+	do(t, router, http.MethodGet, "/v1/book/"+seedID, "", requestIDHeader, "trace-2")
+
+	var entries []map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n")) {
+		var entry map[string]any
+		if err := json.Unmarshal(line, &entry); err != nil {
+			t.Fatalf("log line %s is not JSON: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("%d log lines, want one per request: %s", len(entries), out.String())
+	}
+
+	for i, want := range []map[string]any{
+		{"level": "info", "method": "GET", "path": "/v1/book", "status": 200.0, "client_ip": "192.0.2.1", "request_id": "trace-1"},
+		{"level": "warning", "method": "GET", "path": "/v1/book/" + seedID, "status": 404.0, "client_ip": "192.0.2.1", "request_id": "trace-2"},
+	} {
+		entry := entries[i]
+		for key, value := range want {
+			if entry[key] != value {
+				t.Errorf("entry %d: %s = %v, want %v", i, key, entry[key], value)
+			}
+		}
+		if latency, ok := entry["latency"].(string); !ok {
+			t.Errorf("entry %d: latency %v", i, entry["latency"])
+		} else if _, err := time.ParseDuration(latency); err != nil {
+			t.Errorf("entry %d: latency %q: %v", i, latency, err)
+		}
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		proxies    []string
+		remoteAddr string
+		wantIP     string
+	}{
+		{"trusted proxy", []string{"10.0.0.0/8"}, "10.1.2.3:5000", "203.0.113.7"},
+		{"untrusted peer", []string{"10.0.0.0/8"}, "192.0.2.1:5000", "192.0.2.1"},
+		{"loopback default", []string{"127.0.0.1", "::1"}, "[::1]:5000", "203.0.113.7"},
+		{"no proxies", []string{}, "10.1.2.3:5000", "10.1.2.3"},
+	} {
+		logger, hook := test.NewNullLogger()
+		bs := newBookService(NewMemoryStore(), logger)
+		bs.TrustedProxies = tt.proxies
+		router := setupRouter(bs)
+
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		req.RemoteAddr = tt.remoteAddr
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		if entry := hook.LastEntry(); entry == nil || entry.Data["client_ip"] != tt.wantIP {
+			t.Errorf("%s: logged %v, want client_ip %s", tt.name, entry, tt.wantIP)
+		}
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	bs := newBookService(NewMemoryStore(), logger)
+	bs.ready.Store(true)
+
+	router := setupRouter(bs)
+	router.GET("/v1/panic", func(c *gin.Context) { panic("secret detail") })
+
+	w := do(t, router, http.MethodGet, "/v1/panic", "")
+	if w.Code != http.StatusInternalServerError || errorCode(t, w) != CodeInternal {
+		t.Fatalf("status %d, body %s; want 500 %s", w.Code, w.Body, CodeInternal)
+	}
+	if strings.Contains(w.Body.String(), "secret detail") {
+		t.Errorf("panic value leaked to the client: %s", w.Body)
+	}
+
+	var panicked, handled bool
+	for _, entry := range hook.AllEntries() {
+		switch entry.Message {
+		case "Panic while handling request":
+			panicked = entry.Data["error"] == "secret detail" &&
+				strings.Contains(entry.Data["stack"].(string), "TestRecovery")
+		case "Request handled":
+			handled = entry.Data["status"] == http.StatusInternalServerError
+		}
+	}
+	if !panicked {
+		t.Error("panic not logged with its value and stack")
+	}
+	if !handled {
+		t.Error("access log has no 500 for the panicking request")
+	}
+
+	if want := `http_requests_total{method="GET",path="/v1/panic",status="500"} 1`; !strings.Contains(scrape(t, router), want) {
+		t.Errorf("/metrics has no %q", want)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+func loadSpec(t *testing.T) *openapi3.T {
+	t.Helper()
+
+	doc, err := openapi3.NewLoader().LoadFromData(openAPISpec)
+	if err != nil {
+		t.Fatalf("parsing openapi.json: %v", err)
+	}
+
+	return doc
+}
+
+func TestOpenAPISpecIsValid(t *testing.T) {
+	if err := loadSpec(t).Validate(context.Background()); err != nil {
+		t.Fatalf("openapi.json is not valid OpenAPI 3: %v", err)
+	}
+}
+
+var ginParam = regexp.MustCompile(`:([^/]+)`)
+
+// TestOpenAPISpecMatchesRoutes checks that every route is documented and
+// every documented operation is routed. The deprecated unversioned book
+// routes are left out of the spec on purpose.
+func TestOpenAPISpecMatchesRoutes(t *testing.T) {
+	doc := loadSpec(t)
+	bs := newTestService(t)
+	bs.EnableWipe = true
+	bs.AdminKeys = []string{"admin"}
+	router := setupRouter(bs)
+
+	routed := make(map[string]bool)
+	for _, route := range router.Routes() {
+		if strings.HasPrefix(route.Path, "/book") {
+			continue
+		}
+
+		path := ginParam.ReplaceAllString(route.Path, "{$1}")
+		routed[route.Method+" "+path] = true
+
+		if item := doc.Paths.Find(path); item == nil || item.GetOperation(route.Method) == nil {
+			t.Errorf("%s %s is routed but not documented", route.Method, path)
+		}
+	}
+
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			if !routed[method+" "+path] {
+				t.Errorf("%s %s is documented but not routed", method, path)
+			}
+		}
+	}
+}
+
+// TestOpenAPISpecMiddlewareResponses checks that the responses the global
+// middleware can send are documented.
+func TestOpenAPISpecMiddlewareResponses(t *testing.T) {
+	doc := loadSpec(t)
+
+	for path, item := range doc.Paths.Map() {
+		for method, op := range item.Operations() {
+			if op.Responses.Status(http.StatusTooManyRequests) == nil {
+				t.Errorf("%s %s does not document 429", method, path)
+			}
+			if op.RequestBody != nil && op.Responses.Status(http.StatusRequestEntityTooLarge) == nil {
+				t.Errorf("%s %s takes a body but does not document 413", method, path)
+			}
+			if !streamsResponse(httptest.NewRequest(method, path, nil)) &&
+				op.Responses.Status(http.StatusServiceUnavailable) == nil {
+				t.Errorf("%s %s does not document 503", method, path)
+			}
+		}
+	}
+}
+
+func TestServeOpenAPIAndDocs(t *testing.T) {
+	bs := newTestService(t)
+	bs.APIKeys = []string{"secret"}
+	router := setupRouter(bs)
+
+	w := do(t, router, http.MethodGet, "/openapi.json", "")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		t.Fatalf("/openapi.json: status %d, Content-Type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	spec := decode[struct {
+		OpenAPI string         `json:"openapi"`
+		Paths   map[string]any `json:"paths"`
+	}](t, w)
+	if !strings.HasPrefix(spec.OpenAPI, "3.0") {
+		t.Errorf("openapi %q, want 3.0", spec.OpenAPI)
+	}
+	for _, path := range []string{"/v1/book", "/v1/book/{id}", "/v1/book/search", "/v1/book/count"} {
+		if spec.Paths[path] == nil {
+			t.Errorf("served spec has no %s", path)
+		}
+	}
+
+	w = do(t, router, http.MethodGet, "/docs", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `url: "/openapi.json"`) {
+		t.Errorf("/docs: status %d, body %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const pgUniqueViolation = "23505"
+
+type PostgresStore struct {
+	DB *sql.DB
+}
+
+func NewPostgresStore(databaseURL string) (*PostgresStore, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migratePostgres(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &PostgresStore{DB: db}, nil
+}
+
+func migratePostgres(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS books (
+		id TEXT PRIMARY KEY,
+		name TEXT,
+		author TEXT
+	);
+	ALTER TABLE books ADD COLUMN IF NOT EXISTS isbn TEXT NOT NULL DEFAULT '';
+	ALTER TABLE books ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ;
+	ALTER TABLE books ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+	ALTER TABLE books ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+	ALTER TABLE books ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+	CREATE UNIQUE INDEX IF NOT EXISTS ` + isbnIndex + ` ON books (isbn) WHERE isbn <> ''`)
+	return err
+}
+
+func (ps *PostgresStore) GetAll(ctx context.Context) ([]Book, error) {
+	return queryBooks(ctx, ps.DB, `SELECT `+bookColumns+` FROM books`)
+}
+
+func (ps *PostgresStore) GetByID(ctx context.Context, id string) (Book, error) {
+	book, err := scanBook(ps.DB.QueryRowContext(ctx, `SELECT `+bookColumns+` FROM books WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Book{}, ErrNotFound
+	}
+
+	return book, err
+}
+
+const pgInsertBook = `INSERT INTO books (` + bookColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+func pgInsert(ctx context.Context, db execer, book Book) error {
+	_, err := db.ExecContext(ctx, pgInsertBook,
+		book.ID, book.Name, book.Author, book.ISBN, book.CreatedAt, book.UpdatedAt, book.DeletedAt, book.Version)
+
+	return pgError(err)
+}
+
+// pgError maps unique violations to the store errors by constraint.
+func pgError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation {
+		if pqErr.Constraint == isbnIndex {
+			return ErrDuplicateISBN
+		}
+		return ErrConflict
+	}
+
+	return err
+}
+
+func (ps *PostgresStore) Create(ctx context.Context, book Book) error {
+	return pgInsert(ctx, ps.DB, book)
+}
+
+func (ps *PostgresStore) CreateBatch(ctx context.Context, books []Book) error {
+	return inTx(ctx, ps.DB, func(tx *sql.Tx) error {
+		for i, book := range books {
+			if err := pgInsert(ctx, tx, book); err != nil {
+				return &BatchError{Index: i, Err: err}
+			}
+		}
+		return nil
+	})
+}
+
+func (ps *PostgresStore) Update(ctx context.Context, id string, book Book) error {
+	res, err := ps.DB.ExecContext(ctx, `UPDATE books SET name = $1, author = $2, isbn = $3,
+		created_at = $4, updated_at = $5, deleted_at = $6, version = $7
+		WHERE id = $8 AND version = $9`,
+		book.Name, book.Author, book.ISBN, book.CreatedAt, book.UpdatedAt, book.DeletedAt,
+		book.Version, id, book.Version-1)
+	if err != nil {
+		return pgError(err)
+	}
+
+	return checkUpdated(ctx, ps.DB, res, `SELECT 1 FROM books WHERE id = $1`, id)
+}
+
+func (ps *PostgresStore) Delete(ctx context.Context, id string) error {
+	res, err := ps.DB.ExecContext(ctx, `DELETE FROM books WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+func (ps *PostgresStore) SoftDeleteBatch(ctx context.Context, ids []string, deletedAt time.Time) ([]string, error) {
+	return softDeleteBatch(ctx, ps.DB,
+		`UPDATE books SET deleted_at = $1, version = version + 1
+		WHERE id = $2 AND deleted_at IS NULL`, ids, deletedAt)
+}
+
+func (ps *PostgresStore) DeleteAll(ctx context.Context) (int, error) {
+	return deleteAllBooks(ctx, ps.DB)
+}
+
+func (ps *PostgresStore) Count(ctx context.Context) (int, error) {
+	return countBooks(ctx, ps.DB)
+}
+
+func (ps *PostgresStore) Close() error {
+	return ps.DB.Close()
+}
+
+func (ps *PostgresStore) Ready(ctx context.Context) error {
+	return ps.DB.PingContext(ctx)
+}
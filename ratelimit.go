@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+const (
+	limiterIdleTTL         = 10 * time.Minute
+	limiterCleanupInterval = time.Minute
+)
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+type RateLimiter struct {
+	mu      sync.Mutex
+	clients map[string]*clientLimiter
+	rate    rate.Limit
+	burst   int
+}
+
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		clients: make(map[string]*clientLimiter),
+		rate:    rate.Limit(perSecond),
+		burst:   burst,
+	}
+}
+
+func (rl *RateLimiter) limiter(key string, now time.Time) *rate.Limiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	client, exist := rl.clients[key]
+	if !exist {
+		client = &clientLimiter{limiter: rate.NewLimiter(rl.rate, rl.burst)}
+		rl.clients[key] = client
+	}
+	client.lastSeen = now
+
+	return client.limiter
+}
+
+func (rl *RateLimiter) cleanup(now time.Time) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	for key, client := range rl.clients {
+		if now.Sub(client.lastSeen) > limiterIdleTTL {
+			delete(rl.clients, key)
+		}
+	}
+}
+
+// cleanupLoop drops limiters for clients that have gone idle until ctx is done.
+func (rl *RateLimiter) cleanupLoop(ctx context.Context) {
+	ticker := time.NewTicker(limiterCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			rl.cleanup(now)
+		}
+	}
+}
+
+func (rl *RateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now()
+
+		reservation := rl.limiter(c.ClientIP(), now).ReserveN(now, 1)
+		if !reservation.OK() {
+			respondError(c, http.StatusTooManyRequests, CodeRateLimited, "rate limit exceeded")
+			return
+		}
+
+		if delay := reservation.DelayFrom(now); delay > 0 {
+			reservation.CancelAt(now)
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			respondError(c, http.StatusTooManyRequests, CodeRateLimited, "rate limit exceeded")
+			return
+		}
+
+		c.Next()
+	}
+}
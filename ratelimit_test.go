@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	bs := newTestService(t)
+	bs.RateLimiter = NewRateLimiter(0.5, 2)
+	router := setupRouter(bs)
+
+	get := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/book", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := range 2 {
+		if w := get("192.0.2.1:1000"); w.Code != http.StatusOK {
+			t.Fatalf("request %d within the burst: status %d", i+1, w.Code)
+		}
+	}
+
+	w := get("192.0.2.1:1001")
+	if w.Code != http.StatusTooManyRequests || errorCode(t, w) != CodeRateLimited {
+		t.Fatalf("over the limit: status %d, body %s", w.Code, w.Body)
+	}
+	if retry := w.Header().Get("Retry-After"); retry != "2" {
+		t.Errorf("Retry-After %q, want 2", retry)
+	}
+
+	if w := get("192.0.2.2:1000"); w.Code != http.StatusOK {
+		t.Errorf("another client: status %d, want 200", w.Code)
+	}
+}
+
+func TestRateLimiterCleanup(t *testing.T) {
+	rl := NewRateLimiter(1, 1)
+	now := time.Now()
+
+	rl.limiter("idle", now)
+	rl.limiter("active", now.Add(limiterIdleTTL))
+	rl.cleanup(now.Add(limiterIdleTTL + time.Second))
+
+	if _, ok := rl.clients["idle"]; ok {
+		t.Error("idle client was not dropped")
+	}
+	if _, ok := rl.clients["active"]; !ok {
+		t.Error("active client was dropped")
+	}
+}
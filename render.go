@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const mimeCSV = "text/csv"
+
+var bookCSVHeader = []string{"id", "name", "author", "isbn", "created_at", "updated_at", "deleted_at", "version"}
+
+// negotiateFormat picks the response format from the Accept header. JSON is
+// used when the header is missing or asks for nothing we can produce.
+func negotiateFormat(c *gin.Context) string {
+	c.Writer.Header().Add("Vary", "Accept")
+
+	switch c.NegotiateFormat(binding.MIMEJSON, binding.MIMEXML, binding.MIMEXML2, mimeCSV) {
+	case binding.MIMEXML, binding.MIMEXML2:
+		return binding.MIMEXML
+	case mimeCSV:
+		return mimeCSV
+	default:
+		return binding.MIMEJSON
+	}
+}
+
+func renderBook(c *gin.Context, status int, book Book) {
+	switch negotiateFormat(c) {
+	case binding.MIMEXML:
+		c.XML(status, book)
+	case mimeCSV:
+		renderCSV(c, status, []Book{book})
+	default:
+		c.JSON(status, book)
+	}
+}
+
+func renderBookPage(c *gin.Context, status int, page BookPage) {
+	switch negotiateFormat(c) {
+	case binding.MIMEXML:
+		c.XML(status, page)
+	case mimeCSV:
+		renderCSV(c, status, page.Data)
+	default:
+		c.JSON(status, page)
+	}
+}
+
+func renderCSV(c *gin.Context, status int, books []Book) {
+	c.Header("Content-Type", mimeCSV+"; charset=utf-8")
+	c.Status(status)
+
+	if err := writeBooksCSV(c.Writer, books); err != nil {
+		c.Error(err)
+	}
+}
+
+func writeBooksCSV(w io.Writer, books []Book) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(bookCSVHeader); err != nil {
+		return err
+	}
+
+	for _, book := range books {
+		deletedAt := ""
+		if book.deleted() {
+			deletedAt = book.DeletedAt.Format(time.RFC3339Nano)
+		}
+
+		record := []string{
+			book.ID,
+			book.Name,
+			book.Author,
+			book.ISBN,
+			book.CreatedAt.Format(time.RFC3339Nano),
+			book.UpdatedAt.Format(time.RFC3339Nano),
+			deletedAt,
+			strconv.Itoa(book.Version),
+		}
+
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newClockedRouter returns a router over a store holding two books, created
+// at a fixed time.
+func newClockedRouter(t *testing.T) http.Handler {
+	t.Helper()
+
+	bs := newTestService(t)
+	bs.Now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+	router := setupRouter(bs)
+
+	postBook(t, router, `{"id":"`+seedID+`","name":"Emma","author":"Austen"}`)
+	postBook(t, router, `{"id":"22222222-2222-2222-2222-222222222222","name":"Dune, Messiah","author":"Herbert","isbn":"0306406152"}`)
+
+	return router
+}
+
+func TestListCSV(t *testing.T) {
+	router := newClockedRouter(t)
+
+	w := do(t, router, http.MethodGet, "/v1/book", "", "Accept", "text/csv")
+	if ct := w.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type %q", ct)
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		bookCSVHeader,
+		{seedID, "Emma", "Austen", "", "2024-05-01T12:00:00Z", "2024-05-01T12:00:00Z", "", "1"},
+		{"22222222-2222-2222-2222-222222222222", "Dune, Messiah", "Herbert", "0306406152", "2024-05-01T12:00:00Z", "2024-05-01T12:00:00Z", "", "1"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("%d records, want %d: %q", len(records), len(want), records)
+	}
+	for i := range want {
+		if strings.Join(records[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("record %d = %q, want %q", i, records[i], want[i])
+		}
+	}
+}
+
+func TestContentNegotiation(t *testing.T) {
+	router := newClockedRouter(t)
+
+	for _, tt := range []struct {
+		path, accept string
+		wantType     string
+		wantPrefix   string
+	}{
+		{"/v1/book/" + seedID, "application/xml", "application/xml; charset=utf-8", `<book><id>` + seedID + `</id><name>Emma</name>`},
+		{"/v1/book", "text/xml", "application/xml; charset=utf-8", `<books total="2" limit="50" offset="0"><book>`},
+		{"/v1/book/" + seedID, "text/csv", "text/csv; charset=utf-8", "id,name,author"},
+		{"/v1/book/" + seedID, "", "application/json; charset=utf-8", `{"id":"` + seedID + `"`},
+		{"/v1/book/" + seedID, "image/png", "application/json; charset=utf-8", `{"id":"` + seedID + `"`},
+	} {
+		w := do(t, router, http.MethodGet, tt.path, "", "Accept", tt.accept)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != tt.wantType ||
+			!strings.HasPrefix(w.Body.String(), tt.wantPrefix) {
+			t.Errorf("GET %s, Accept %q: status %d, Content-Type %q, body %s",
+				tt.path, tt.accept, w.Code, w.Header().Get("Content-Type"), w.Body)
+		}
+		if vary := w.Header().Values("Vary"); !strings.Contains(strings.Join(vary, ","), "Accept") {
+			t.Errorf("GET %s: Vary %q", tt.path, vary)
+		}
+	}
+}
+
+func TestXMLAndJSONRenderTheSameBook(t *testing.T) {
+	router := newClockedRouter(t)
+	path := "/v1/book/22222222-2222-2222-2222-222222222222"
+
+	asJSON := decode[Book](t, do(t, router, http.MethodGet, path, "", "Accept", "application/json"))
+
+	w := do(t, router, http.MethodGet, path, "", "Accept", "application/xml")
+	var asXML Book
+	if err := xml.Unmarshal(w.Body.Bytes(), &asXML); err != nil {
+		t.Fatalf("body %s: %v", w.Body, err)
+	}
+	asXML.XMLName = xml.Name{}
+
+	if !reflect.DeepEqual(asXML, asJSON) {
+		t.Errorf("XML %+v\nJSON %+v", asXML, asJSON)
+	}
+	if asXML.Name != "Dune, Messiah" || asXML.ISBN != "0306406152" || asXML.Version != 1 {
+		t.Errorf("decoded %+v", asXML)
+	}
+
+	for _, accept := range []string{"application/json, application/xml", "text/html", "*/*"} {
+		if ct := do(t, router, http.MethodGet, path, "", "Accept", accept).Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+			t.Errorf("Accept %q: Content-Type %q, want JSON", accept, ct)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	apiVersionPrefix = "/v1"
+	bookPathPrefix   = apiVersionPrefix + "/book/"
+)
+
+// setupRouter builds the complete handler tree for bs, so it can be served
+// by main or exercised directly with httptest.
+func setupRouter(bs *BookService) *gin.Engine {
+	router := gin.New()
+
+	// ClientIP only reads forwarding headers from these peers. The list was
+	// validated when it was parsed, so SetTrustedProxies can't fail here.
+	router.SetTrustedProxies(bs.TrustedProxies)
+	router.Use(accessLog(bs.Logger), requestID())
+
+	if bs.Metrics != nil {
+		router.Use(bs.Metrics.Middleware())
+	}
+
+	// Recovery sits inside the access log and metrics so a panic still
+	// reaches both as the 500 it was answered with.
+	router.Use(recovery(bs.Logger))
+
+	if len(bs.CORS.AllowedOrigins) > 0 {
+		router.Use(cors(bs.CORS))
+	}
+
+	if bs.RequestTimeout > 0 {
+		router.Use(requestTimeout(bs.RequestTimeout))
+	}
+
+	if bs.MaxBodyBytes > 0 {
+		router.Use(limitRequestBody(bs.MaxBodyBytes))
+	}
+
+	if bs.GzipMinSize > 0 {
+		router.Use(gzipResponses(bs.GzipMinSize))
+	}
+
+	if bs.RateLimiter != nil {
+		router.Use(bs.RateLimiter.Middleware())
+	}
+
+	registerRoutes(router, bs)
+
+	return router
+}
+
+// registerRoutes adds every route to r without any global middleware.
+func registerRoutes(r *gin.Engine, bs *BookService) {
+	// Probes, metrics and the API description are registered outside every
+	// auth group so orchestrators, scrapers and client generators can reach
+	// them without credentials.
+	r.GET("/healthz", healthz)
+	r.GET("/readyz", bs.readyz)
+	if bs.Metrics != nil {
+		r.GET(metricsPath, bs.Metrics.Handler())
+	}
+	r.GET("/openapi.json", serveOpenAPI)
+	r.GET("/docs", serveDocs)
+
+	v1 := r.Group(apiVersionPrefix)
+	registerBookRoutes(v1, bs)
+	registerEntityRoutes(v1, bs)
+
+	// The unversioned paths are kept for one release so existing clients
+	// have time to move to /v1.
+	registerBookRoutes(r.Group("", bs.deprecatedRoutes()), bs)
+}
+
+// authHandlers returns the middleware guarding reads and the extra
+// middleware guarding writes, following AuthMode.
+func (bs *BookService) authHandlers() (reads, writes []gin.HandlerFunc) {
+	if len(bs.APIKeys) > 0 {
+		if bs.AuthMode == authModeWrite {
+			writes = append(writes, apiKeyAuth(bs.APIKeys))
+		} else {
+			reads = append(reads, apiKeyAuth(bs.APIKeys))
+		}
+	}
+
+	if len(bs.JWTSecret) > 0 {
+		writes = append(writes, jwtAuth(bs.JWTSecret))
+	}
+
+	return reads, writes
+}
+
+// registerBookRoutes mounts the book API on group. It is shared by the /v1
+// group and the deprecated unversioned paths.
+func registerBookRoutes(group *gin.RouterGroup, bs *BookService) {
+	readAuth, writeAuth := bs.authHandlers()
+	group = group.Group("", readAuth...)
+	books := bs.bookResource()
+
+	group.GET("/book", books.list)
+	group.GET("/book/count", bs.countBooks)
+	group.GET("/book/search", bs.searchBooks)
+	group.GET("/book/export", bs.exportBooks)
+	group.GET("/book/export.csv", bs.exportBooksCSV)
+	if bs.Events != nil {
+		group.GET("/book/events", bs.streamEventsWS)
+		group.GET("/book/stream", bs.streamEventsSSE)
+	}
+	group.GET("/book/:id", books.get)
+	// net/http drops the body of HEAD responses, so the GET handler gives
+	// identical status and headers.
+	group.HEAD("/book/:id", books.get)
+
+	writes := group.Group("", writeAuth...)
+
+	create := []gin.HandlerFunc{books.create}
+	if bs.Idempotency != nil {
+		create = append([]gin.HandlerFunc{bs.Idempotency.Middleware()}, create...)
+	}
+
+	writes.POST("/book", create...)
+	writes.PUT("/book/:id", books.replace)
+	writes.PATCH("/book/:id", bs.patchBook)
+	if bs.EnableWipe && len(bs.AdminKeys) > 0 {
+		writes.DELETE("/book", adminKeyAuth(bs.AdminKeys), bs.deleteAllBooks)
+	}
+	writes.DELETE("/book/:id", books.delete)
+	writes.POST("/book/:id/restore", bs.restoreBook)
+	writes.POST("/book/import", bs.importBooks)
+
+	writes.POST("/books/batch", bs.createBooksBatch)
+	writes.POST("/books/bulk", bs.createBooksBatch)
+	writes.DELETE("/books/bulk", bs.deleteBooksBulk)
+}
+
+// registerEntityRoutes mounts the entities served by the generic handlers.
+// They were added after versioning, so they only exist under /v1.
+func registerEntityRoutes(group *gin.RouterGroup, bs *BookService) {
+	readAuth, writeAuth := bs.authHandlers()
+	group = group.Group("", readAuth...)
+
+	if bs.Authors != nil {
+		RegisterCRUD(group, "/author", bs.Authors, bs.Logger, writeAuth...)
+	}
+	if bs.Publishers != nil {
+		RegisterCRUD(group, "/publisher", bs.Publishers, bs.Logger, writeAuth...)
+	}
+}
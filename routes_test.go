@@ -0,0 +1,136 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestLegacyRoutes(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	bs := newBookService(NewMemoryStore(), logger)
+	bs.ready.Store(true)
+	router := setupRouter(bs)
+
+	book := postBook(t, router, `{"name":"Emma","author":"Austen"}`)
+
+	v1 := do(t, router, http.MethodGet, "/v1/book/"+book.ID, "")
+	if v1.Header().Get("Deprecation") != "" {
+		t.Error("/v1 response is marked deprecated")
+	}
+
+	hook.Reset()
+	legacy := do(t, router, http.MethodGet, "/book/"+book.ID, "")
+	if legacy.Code != http.StatusOK || legacy.Body.String() != v1.Body.String() {
+		t.Errorf("legacy path: status %d, body %s; want the /v1 response %s", legacy.Code, legacy.Body, v1.Body)
+	}
+	if legacy.Header().Get("Deprecation") != "true" ||
+		legacy.Header().Get("Link") != `</v1/book/`+book.ID+`>; rel="successor-version"` {
+		t.Errorf("legacy headers %v", legacy.Header())
+	}
+
+	var warned bool
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == logrus.WarnLevel && entry.Data["endpoint"] == "/book/"+book.ID {
+			warned = entry.Data["client_ip"] == "192.0.2.1"
+		}
+	}
+	if !warned {
+		t.Error("legacy call wasn't logged as deprecated with the client IP")
+	}
+
+	if w := do(t, router, http.MethodPost, "/book", `{"name":"Dune","author":"Herbert"}`); w.Code != http.StatusCreated {
+		t.Errorf("legacy create: status %d", w.Code)
+	}
+	if total := decode[BookPage](t, do(t, router, http.MethodGet, "/v1/book", "")).Total; total != 2 {
+		t.Errorf("%d books, want the legacy create in the same store", total)
+	}
+}
+
+func TestRegisterRoutes(t *testing.T) {
+	routes := func(bs *BookService) map[string]bool {
+		r := gin.New()
+		registerRoutes(r, bs)
+
+		set := map[string]bool{}
+		for _, route := range r.Routes() {
+			set[route.Method+" "+route.Path] = true
+		}
+		return set
+	}
+
+	logger, _ := test.NewNullLogger()
+	bs := newBookService(NewMemoryStore(), logger)
+	got := routes(bs)
+	for _, want := range []string{
+		"GET /healthz", "GET /readyz", "GET " + metricsPath, "GET /openapi.json", "GET /docs",
+		"GET /v1/book", "GET /v1/book/:id", "HEAD /v1/book/:id", "POST /v1/book",
+		"PUT /v1/book/:id", "PATCH /v1/book/:id", "DELETE /v1/book/:id", "POST /v1/book/:id/restore",
+		"GET /v1/book/search", "GET /v1/book/count", "GET /v1/book/events", "GET /v1/book/stream",
+		"POST /v1/books/batch", "DELETE /v1/books/bulk",
+		"GET /v1/author", "POST /v1/author", "GET /v1/publisher/:id",
+		"GET /book", "POST /book", "DELETE /book/:id",
+	} {
+		if !got[want] {
+			t.Errorf("%s is not registered", want)
+		}
+	}
+	for _, unwanted := range []string{"DELETE /v1/book", "GET /author"} {
+		if got[unwanted] {
+			t.Errorf("%s is registered", unwanted)
+		}
+	}
+
+	bs.EnableWipe = true
+	bs.AdminKeys = []string{"admin"}
+	if !routes(bs)["DELETE /v1/book"] {
+		t.Error("DELETE /v1/book is not registered with -enable-wipe and admin keys")
+	}
+
+	r := gin.New()
+	registerRoutes(r, newBookService(NewMemoryStore(), logger))
+	if w := do(t, r, http.MethodPost, "/v1/book", `{"name":"Emma","author":"Austen"}`); w.Code != http.StatusCreated {
+		t.Errorf("create on a bare engine: status %d, body %s", w.Code, w.Body)
+	}
+}
+
+func TestHeadBook(t *testing.T) {
+	bs := newTestService(t)
+	_, url := startServer(t, bs)
+	book := postBook(t, setupRouter(bs), `{"name":"Emma","author":"Austen"}`)
+
+	for _, id := range []string{book.ID, seedID} {
+		get, err := http.Get(url + "/v1/book/" + id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(get.Body)
+		get.Body.Close()
+
+		head, err := http.Head(url + "/v1/book/" + id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		headBody, _ := io.ReadAll(head.Body)
+		head.Body.Close()
+
+		if head.StatusCode != get.StatusCode || len(headBody) != 0 {
+			t.Errorf("%s: HEAD status %d with %d body bytes, GET status %d", id, head.StatusCode, len(headBody), get.StatusCode)
+		}
+		for _, header := range []string{"Content-Type", "Content-Length", "ETag"} {
+			if head.Header.Get(header) != get.Header.Get(header) {
+				t.Errorf("%s: HEAD %s %q, GET %q", id, header, head.Header.Get(header), get.Header.Get(header))
+			}
+		}
+		if id == book.ID && (get.StatusCode != http.StatusOK || head.ContentLength != int64(len(body)) || head.Header.Get("ETag") == "") {
+			t.Errorf("existing book: status %d, HEAD Content-Length %d for a %d byte body", get.StatusCode, head.ContentLength, len(body))
+		}
+		if id == seedID && head.StatusCode != http.StatusNotFound {
+			t.Errorf("missing book: HEAD status %d, want 404", head.StatusCode)
+		}
+	}
+}
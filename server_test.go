@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const runMainEnv = "CRUD_API_RUN_MAIN"
+
+// mainCommand runs main with args in a child process, without picking up
+// auth or database settings from the environment.
+func mainCommand(args ...string) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), runMainEnv+"=1", "JWT_SECRET=", "DATABASE_URL=")
+
+	return cmd
+}
+
+// startMain starts cmd from mainCommand and returns the address it logged
+// once listening. Log lines after that are sent to logs.
+func startMain(t *testing.T, cmd *exec.Cmd) (addr string, logs <-chan map[string]any) {
+	t.Helper()
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cmd.Process.Kill() })
+
+	lines := make(chan map[string]any, 256)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			var entry map[string]any
+			if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+				lines <- entry
+			}
+		}
+		io.Copy(io.Discard, stderr)
+	}()
+
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case entry, ok := <-lines:
+			if !ok {
+				t.Fatal("server exited before listening")
+			}
+			if entry["msg"] == "Server listening" {
+				return entry["addr"].(string), lines
+			}
+		case <-timeout:
+			t.Fatal("server did not start listening")
+		}
+	}
+}
+
+func TestMainShutsDownOnSIGTERM(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts the server binary")
+	}
+
+	cmd := mainCommand("-addr", "127.0.0.1:0", "-backend", "memory")
+	addr, logs := startMain(t, cmd)
+
+	resp, err := http.Get("http://" + addr + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []any
+	for entry := range logs {
+		messages = append(messages, entry["msg"])
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Errorf("server exited with %v", err)
+	}
+
+	want := []any{"Shutting down the server", "Server stopped"}
+	if len(messages) < 2 || messages[len(messages)-2] != want[0] || messages[len(messages)-1] != want[1] {
+		t.Errorf("logged %q, want it to end with %q", messages, want)
+	}
+}
+
+func TestShutdownWaitsForInFlightRequests(t *testing.T) {
+	bs := newStoreService(t, &slowStore{BookStore: NewMemoryStore(), delay: 200 * time.Millisecond})
+	srv, url := startServer(t, bs)
+
+	done := make(chan int)
+	go func() {
+		resp, err := http.Get(url + "/v1/book")
+		if err != nil {
+			t.Error(err)
+			done <- 0
+			return
+		}
+		resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+
+	// Give the request time to reach the store before shutting down.
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := <-done; status != http.StatusOK {
+		t.Errorf("in-flight request: status %d, want 200", status)
+	}
+	if _, err := http.Get(url + "/v1/book"); err == nil {
+		t.Error("server still accepted requests after Shutdown")
+	}
+}
+
+func TestMainFailsWhenAddrIsTaken(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts the server binary")
+	}
+
+	addr, _ := startMain(t, mainCommand("-addr", "127.0.0.1:0", "-backend", "memory"))
+
+	out, err := mainCommand("-addr", addr, "-backend", "memory").CombinedOutput()
+	if err == nil {
+		t.Fatal("a second server started on the same address")
+	}
+	if !bytes.Contains(out, []byte("Error when starting the server")) {
+		t.Errorf("output %s", out)
+	}
+}
+
+func TestMainRejectsInvalidLogLevel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts the server binary")
+	}
+
+	out, err := mainCommand("-loglevel", "verbose").CombinedOutput()
+	if err == nil || !bytes.Contains(out, []byte(`invalid log level \"verbose\"`)) {
+		t.Errorf("exited with %v, output %s", err, out)
+	}
+}
+
+func TestMainRejectsBurstBelowOne(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts the server binary")
+	}
+
+	for _, burst := range []string{"0", "-1"} {
+		out, err := mainCommand("-rate", "1", "-burst", burst).CombinedOutput()
+		if err == nil || !bytes.Contains(out, []byte("invalid burst "+burst)) {
+			t.Errorf("-burst %s: exited with %v, output %s", burst, err, out)
+		}
+	}
+}
+
+func TestMainRateLimitFlags(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts the server binary")
+	}
+
+	addr, _ := startMain(t, mainCommand("-addr", "127.0.0.1:0", "-backend", "memory", "-rate", "1", "-burst", "3"))
+
+	statuses := map[int]int{}
+	for range 6 {
+		resp, err := http.Get("http://" + addr + "/v1/book")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		statuses[resp.StatusCode]++
+
+		if resp.StatusCode == http.StatusTooManyRequests && resp.Header.Get("Retry-After") == "" {
+			t.Error("429 without Retry-After")
+		}
+	}
+
+	if statuses[http.StatusOK] < 3 || statuses[http.StatusTooManyRequests] == 0 {
+		t.Errorf("statuses %v, want the burst of 3 served and the rest limited", statuses)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	bs := newStoreService(t, &slowStore{BookStore: NewMemoryStore(), delay: 5 * time.Second})
+	srv, url := startServer(t, bs)
+
+	go func() {
+		if resp, err := http.Get(url + "/v1/book"); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := srv.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown with a stuck request: %v, want the drain timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Shutdown took %v, want it to give up after the timeout", elapsed)
+	}
+}
+
+func TestMainShutdownTimeoutFlag(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts the server binary")
+	}
+
+	cmd := mainCommand("-addr", "127.0.0.1:0", "-backend", "memory", "-shutdown-timeout", "3s")
+	_, logs := startMain(t, cmd)
+	cmd.Process.Signal(os.Interrupt)
+
+	var timeout any
+	for entry := range logs {
+		if entry["msg"] == "Shutting down the server" {
+			timeout = entry["timeout"]
+		}
+	}
+	cmd.Wait()
+
+	if timeout != "3s" {
+		t.Errorf("shutdown logged with timeout %v, want 3s", timeout)
+	}
+}
+
+func TestMainListensOnADDR(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts the server binary")
+	}
+
+	cmd := mainCommand("-backend", "memory")
+	cmd.Env = append(cmd.Env, "ADDR=127.0.0.1:0")
+	addr, _ := startMain(t, cmd)
+
+	if host, port, err := net.SplitHostPort(addr); err != nil || host != "127.0.0.1" || port == "0" {
+		t.Fatalf("logged listen address %q, want the resolved port on 127.0.0.1", addr)
+	}
+
+	resp, err := http.Get("http://" + addr + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	out, err := mainCommand("-addr", "localhost").CombinedOutput()
+	if err == nil || !bytes.Contains(out, []byte("Error when resolving the listen address")) {
+		t.Errorf("-addr without a port: exited with %v, output %s", err, out)
+	}
+}
+
+func TestMainAPIKeys(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts the server binary")
+	}
+
+	get := func(addr, path, key string) int {
+		req, _ := http.NewRequest(http.MethodGet, "http://"+addr+path, nil)
+		if key != "" {
+			req.Header.Set(apiKeyHeader, key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	cmd := mainCommand("-addr", "127.0.0.1:0", "-backend", "memory")
+	cmd.Env = append(cmd.Env, "API_KEYS=env-one, env-two")
+	addr, _ := startMain(t, cmd)
+
+	for _, tt := range []struct {
+		path, key  string
+		wantStatus int
+	}{
+		{"/v1/book", "", http.StatusUnauthorized},
+		{"/v1/book", "env-two", http.StatusOK},
+		{"/healthz", "", http.StatusOK},
+		{metricsPath, "", http.StatusOK},
+	} {
+		if got := get(addr, tt.path, tt.key); got != tt.wantStatus {
+			t.Errorf("API_KEYS: GET %s with key %q: status %d, want %d", tt.path, tt.key, got, tt.wantStatus)
+		}
+	}
+
+	cmd = mainCommand("-addr", "127.0.0.1:0", "-backend", "memory", "-api-keys", "flag-key", "-auth-mode", "write")
+	cmd.Env = append(cmd.Env, "API_KEYS=env-one")
+	addr, _ = startMain(t, cmd)
+
+	if got := get(addr, "/v1/book", ""); got != http.StatusOK {
+		t.Errorf("-auth-mode write: read without a key: status %d, want 200", got)
+	}
+
+	for key, want := range map[string]int{"flag-key": http.StatusCreated, "env-one": http.StatusUnauthorized} {
+		req, _ := http.NewRequest(http.MethodPost, "http://"+addr+"/v1/book", strings.NewReader(`{"name":"Emma","author":"Austen"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(apiKeyHeader, key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("-api-keys over API_KEYS: write with %q: status %d, want %d", key, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestMainGzipMinSizeFlag(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts the server binary")
+	}
+
+	encoding := func(addr, path string) string {
+		req, _ := http.NewRequest(http.MethodGet, "http://"+addr+path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.Header.Get("Content-Encoding") == "gzip" {
+			if _, err := gzip.NewReader(resp.Body); err != nil {
+				t.Errorf("-gzip-min-size: %s is not gzip: %v", path, err)
+			}
+		}
+		return resp.Header.Get("Content-Encoding")
+	}
+
+	addr, _ := startMain(t, mainCommand("-addr", "127.0.0.1:0", "-backend", "memory", "-gzip-min-size", "30"))
+	if got := encoding(addr, "/v1/book"); got != "gzip" {
+		t.Errorf("-gzip-min-size 30: Content-Encoding %q, want gzip", got)
+	}
+	if got := encoding(addr, "/healthz"); got != "" {
+		t.Errorf("-gzip-min-size 30: /healthz Content-Encoding %q, want it under the threshold", got)
+	}
+
+	addr, _ = startMain(t, mainCommand("-addr", "127.0.0.1:0", "-backend", "memory", "-gzip-min-size", "0"))
+	if got := encoding(addr, "/v1/book"); got != "" {
+		t.Errorf("-gzip-min-size 0: Content-Encoding %q, want compression off", got)
+	}
+}
+
+func TestMainPutUpsertFlag(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts the server binary")
+	}
+
+	put := func(addr, id string) int {
+		req, _ := http.NewRequest(http.MethodPut, "http://"+addr+"/v1/book/"+id, strings.NewReader(`{"name":"Emma","author":"Austen"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	addr, _ := startMain(t, mainCommand("-addr", "127.0.0.1:0", "-backend", "memory"))
+	if got := put(addr, seedID); got != http.StatusCreated {
+		t.Errorf("default: PUT on a missing ID: status %d, want 201", got)
+	}
+	if got := put(addr, seedID); got != http.StatusOK {
+		t.Errorf("default: PUT on an existing ID: status %d, want 200", got)
+	}
+
+	addr, _ = startMain(t, mainCommand("-addr", "127.0.0.1:0", "-backend", "memory", "-put-upsert=false"))
+	if got := put(addr, seedID); got != http.StatusNotFound {
+		t.Errorf("-put-upsert=false: PUT on a missing ID: status %d, want 404", got)
+	}
+}
+
+func TestMainReadyAfterStartup(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts the server binary")
+	}
+
+	addr, _ := startMain(t, mainCommand("-addr", "127.0.0.1:0", "-backend", "file", "-data-file", filepath.Join(t.TempDir(), "books.json")))
+
+	// Readiness is set just after the listener starts serving.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		resp, err := http.Get("http://" + addr + "/readyz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("/readyz still %d after startup", resp.StatusCode)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const bookColumns = "id, name, author, isbn, created_at, updated_at, deleted_at, version"
+
+// isbnIndex enforces ISBN uniqueness; empty ISBNs are exempt.
+const isbnIndex = "books_isbn_unique"
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func inTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanBook(row rowScanner) (Book, error) {
+	var book Book
+	var createdAt, updatedAt, deletedAt sql.NullTime
+
+	err := row.Scan(&book.ID, &book.Name, &book.Author, &book.ISBN,
+		&createdAt, &updatedAt, &deletedAt, &book.Version)
+
+	book.CreatedAt = createdAt.Time
+	book.UpdatedAt = updatedAt.Time
+	if deletedAt.Valid {
+		book.DeletedAt = &deletedAt.Time
+	}
+
+	return book, err
+}
+
+func queryBooks(ctx context.Context, db *sql.DB, query string, args ...any) ([]Book, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	books := []Book{}
+
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, book)
+	}
+
+	return books, rows.Err()
+}
+
+// softDeleteBatch runs query once per id inside a single transaction. The
+// query must take the deletion time and the id, in that order.
+func softDeleteBatch(ctx context.Context, db *sql.DB, query string, ids []string, deletedAt time.Time) ([]string, error) {
+	deleted := []string{}
+
+	err := inTx(ctx, db, func(tx *sql.Tx) error {
+		for _, id := range ids {
+			res, err := tx.ExecContext(ctx, query, deletedAt, id)
+			if err != nil {
+				return err
+			}
+
+			if err := checkAffected(res); errors.Is(err, ErrNotFound) {
+				continue
+			} else if err != nil {
+				return err
+			}
+
+			deleted = append(deleted, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
+
+func deleteAllBooks(ctx context.Context, db *sql.DB) (int, error) {
+	res, err := db.ExecContext(ctx, `DELETE FROM books`)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func countBooks(ctx context.Context, db *sql.DB) (int, error) {
+	var n int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM books WHERE deleted_at IS NULL`).Scan(&n)
+	return n, err
+}
+
+// checkUpdated tells a missing row apart from a version mismatch when a
+// versioned UPDATE matched no rows. existsQuery must select by id alone.
+func checkUpdated(ctx context.Context, db *sql.DB, res sql.Result, existsQuery, id string) error {
+	err := checkAffected(res)
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	var exists int
+	switch err := db.QueryRowContext(ctx, existsQuery, id).Scan(&exists); {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound
+	case err != nil:
+		return err
+	default:
+		return ErrVersionConflict
+	}
+}
+
+func checkAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+type SQLiteStore struct {
+	DB *sql.DB
+}
+
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStore{DB: db}, nil
+}
+
+func migrateSQLite(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS books (
+		id TEXT PRIMARY KEY,
+		name TEXT,
+		author TEXT
+	)`); err != nil {
+		return err
+	}
+
+	columns := []struct{ name, decl string }{
+		{"isbn", "TEXT NOT NULL DEFAULT ''"},
+		{"created_at", "TIMESTAMP"},
+		{"updated_at", "TIMESTAMP"},
+		{"deleted_at", "TIMESTAMP"},
+		{"version", "INTEGER NOT NULL DEFAULT 1"},
+	}
+
+	for _, column := range columns {
+		if err := sqliteAddColumn(db, column.name, column.decl); err != nil {
+			return err
+		}
+	}
+
+	_, err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS ` + isbnIndex + ` ON books (isbn) WHERE isbn <> ''`)
+	return err
+}
+
+// sqliteAddColumn adds a column to books unless it already exists, since
+// SQLite has no ADD COLUMN IF NOT EXISTS.
+func sqliteAddColumn(db *sql.DB, column, decl string) error {
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('books') WHERE name = ?`, column).
+		Scan(&count); err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	_, err := db.Exec(`ALTER TABLE books ADD COLUMN ` + column + ` ` + decl)
+	return err
+}
+
+func (ss *SQLiteStore) GetAll(ctx context.Context) ([]Book, error) {
+	return queryBooks(ctx, ss.DB, `SELECT `+bookColumns+` FROM books`)
+}
+
+func (ss *SQLiteStore) GetByID(ctx context.Context, id string) (Book, error) {
+	book, err := scanBook(ss.DB.QueryRowContext(ctx, `SELECT `+bookColumns+` FROM books WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Book{}, ErrNotFound
+	}
+
+	return book, err
+}
+
+const sqliteInsertBook = `INSERT INTO books (` + bookColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+func sqliteInsert(ctx context.Context, db execer, book Book) error {
+	_, err := db.ExecContext(ctx, sqliteInsertBook,
+		book.ID, book.Name, book.Author, book.ISBN, book.CreatedAt, book.UpdatedAt, book.DeletedAt, book.Version)
+
+	return sqliteError(err)
+}
+
+// sqliteError maps constraint violations to the store errors.
+func sqliteError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.ExtendedCode {
+		case sqlite3.ErrConstraintPrimaryKey:
+			return ErrConflict
+		case sqlite3.ErrConstraintUnique:
+			return ErrDuplicateISBN
+		}
+	}
+
+	return err
+}
+
+func (ss *SQLiteStore) Create(ctx context.Context, book Book) error {
+	return sqliteInsert(ctx, ss.DB, book)
+}
+
+func (ss *SQLiteStore) CreateBatch(ctx context.Context, books []Book) error {
+	return inTx(ctx, ss.DB, func(tx *sql.Tx) error {
+		for i, book := range books {
+			if err := sqliteInsert(ctx, tx, book); err != nil {
+				return &BatchError{Index: i, Err: err}
+			}
+		}
+		return nil
+	})
+}
+
+func (ss *SQLiteStore) Update(ctx context.Context, id string, book Book) error {
+	res, err := ss.DB.ExecContext(ctx, `UPDATE books SET name = ?, author = ?, isbn = ?,
+		created_at = ?, updated_at = ?, deleted_at = ?, version = ?
+		WHERE id = ? AND version = ?`,
+		book.Name, book.Author, book.ISBN, book.CreatedAt, book.UpdatedAt, book.DeletedAt,
+		book.Version, id, book.Version-1)
+	if err != nil {
+		return sqliteError(err)
+	}
+
+	return checkUpdated(ctx, ss.DB, res, `SELECT 1 FROM books WHERE id = ?`, id)
+}
+
+func (ss *SQLiteStore) Delete(ctx context.Context, id string) error {
+	res, err := ss.DB.ExecContext(ctx, `DELETE FROM books WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+func (ss *SQLiteStore) SoftDeleteBatch(ctx context.Context, ids []string, deletedAt time.Time) ([]string, error) {
+	return softDeleteBatch(ctx, ss.DB,
+		`UPDATE books SET deleted_at = ?, version = version + 1
+		WHERE id = ? AND deleted_at IS NULL`, ids, deletedAt)
+}
+
+func (ss *SQLiteStore) DeleteAll(ctx context.Context) (int, error) {
+	return deleteAllBooks(ctx, ss.DB)
+}
+
+func (ss *SQLiteStore) Count(ctx context.Context) (int, error) {
+	return countBooks(ctx, ss.DB)
+}
+
+func (ss *SQLiteStore) Close() error {
+	return ss.DB.Close()
+}
+
+func (ss *SQLiteStore) Ready(ctx context.Context) error {
+	return ss.DB.PingContext(ctx)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStoreSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "books.db")
+
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	book := Book{ID: seedID, Name: "Emma", Author: "Austen", CreatedAt: created, UpdatedAt: created, Version: 1}
+	if err := store.Create(ctx, book); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Create(ctx, book); !errors.Is(err, ErrConflict) {
+		t.Errorf("second Create: %v, want ErrConflict", err)
+	}
+	store.Close()
+
+	store, err = NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer store.Close()
+
+	got, err := store.GetByID(ctx, seedID)
+	if err != nil {
+		t.Fatalf("after restart: %v", err)
+	}
+	if got.Name != "Emma" || got.Author != "Austen" || !got.CreatedAt.Equal(created) || got.Version != 1 {
+		t.Errorf("after restart = %+v", got)
+	}
+
+	if _, err := store.GetByID(ctx, "22222222-2222-2222-2222-222222222222"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetByID of a missing ID: %v, want ErrNotFound", err)
+	}
+}
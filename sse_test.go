@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startServer serves bs on a local port the way main does, with the event
+// hub closed on shutdown, and returns the server and its base URL.
+func startServer(t *testing.T, bs *BookService) (*http.Server, string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &http.Server{Handler: setupRouter(bs)}
+	srv.RegisterOnShutdown(bs.Events.Close)
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	return srv, "http://" + ln.Addr().String()
+}
+
+// shutdownQuickly fails the test unless srv shuts down well within its
+// timeout.
+func shutdownQuickly(t *testing.T, srv *http.Server) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown after %v: %v", time.Since(start), err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Shutdown took %v", elapsed)
+	}
+}
+
+func TestSSEStream(t *testing.T) {
+	srv, url := startServer(t, newTestService(t))
+
+	resp, err := http.Get(url + "/v1/book/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type %q", ct)
+	}
+
+	created, err := http.Post(url+"/v1/book", "application/json", strings.NewReader(`{"name":"Emma","author":"Austen"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	created.Body.Close()
+
+	lines := bufio.NewScanner(resp.Body)
+	var event []string
+	for lines.Scan() && lines.Text() != "" {
+		event = append(event, lines.Text())
+	}
+	if len(event) != 2 || event[0] != "event: "+eventCreated || !strings.Contains(event[1], `"name":"Emma"`) {
+		t.Errorf("event %q", event)
+	}
+
+	shutdownQuickly(t, srv)
+
+	for lines.Scan() {
+	}
+	if err := lines.Err(); err != nil {
+		t.Errorf("stream ended with %v, want EOF", err)
+	}
+}
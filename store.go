@@ -0,0 +1,477 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultSQLitePath = "books.db"
+
+var (
+	ErrNotFound = errors.New("record not found")
+	ErrConflict = errors.New("record already exists")
+
+	// ErrDuplicateISBN is returned when a create or update would give two
+	// books the same non-empty ISBN. Soft-deleted books keep their ISBN.
+	ErrDuplicateISBN = errors.New("isbn already in use")
+
+	// ErrDuplicateName is only returned when unique names are enforced.
+	ErrDuplicateName = errors.New("name already in use")
+
+	// ErrVersionConflict is returned by Update when the stored book is no
+	// longer at the version the update was based on.
+	ErrVersionConflict = errors.New("record was modified concurrently")
+)
+
+// BatchError reports which entry of a batch caused it to be rejected.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("book at index %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// BookStore is implemented by every storage backend. Each method gives up
+// with ctx's error once ctx is done, without changing anything.
+type BookStore interface {
+	GetAll(ctx context.Context) ([]Book, error)
+	GetByID(ctx context.Context, id string) (Book, error)
+	Create(ctx context.Context, book Book) error
+	CreateBatch(ctx context.Context, books []Book) error
+	// Update replaces the book stored under id. book.Version must be exactly
+	// one more than the stored version.
+	Update(ctx context.Context, id string, book Book) error
+	Delete(ctx context.Context, id string) error
+	SoftDeleteBatch(ctx context.Context, ids []string, deletedAt time.Time) (deleted []string, err error)
+	// DeleteAll permanently removes every book, soft-deleted ones included,
+	// as one operation and reports how many there were.
+	DeleteAll(ctx context.Context) (int, error)
+	// Count reports how many books are not soft-deleted without loading
+	// them.
+	Count(ctx context.Context) (int, error)
+	Ready(ctx context.Context) error
+}
+
+// openStore picks a backend by name. When backend is empty it is inferred
+// from the configuration that is present, falling back to memory.
+func openStore(backend, dbPath, dataFile string) (BookStore, error) {
+	databaseURL := os.Getenv("DATABASE_URL")
+
+	if backend == "" {
+		switch {
+		case databaseURL != "":
+			backend = "postgres"
+		case dbPath != "":
+			backend = "sqlite"
+		case dataFile != "":
+			backend = "file"
+		default:
+			backend = "memory"
+		}
+	}
+
+	switch backend {
+	case "memory":
+		return NewMemoryStore(), nil
+	case "sqlite":
+		if dbPath == "" {
+			dbPath = defaultSQLitePath
+		}
+		return NewSQLiteStore(dbPath)
+	case "postgres":
+		if databaseURL == "" {
+			return nil, errors.New("DATABASE_URL is not set")
+		}
+		return NewPostgresStore(databaseURL)
+	case "file":
+		if dataFile == "" {
+			return nil, errors.New("-data-file is not set")
+		}
+		return NewJSONFileStore(dataFile)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
+
+// memoryShards is the number of independently locked maps a MemoryStore
+// spreads books over, so writes to different IDs rarely contend.
+const memoryShards = 32
+
+type memoryShard struct {
+	mu    sync.RWMutex
+	books map[string]Book
+}
+
+type MemoryStore struct {
+	shards [memoryShards]*memoryShard
+
+	// isbnMu guards isbns, which maps each ISBN in use to its book's ID. It
+	// is only ever taken while holding the lock of the shard being changed.
+	isbnMu sync.Mutex
+	isbns  map[string]string
+}
+
+func NewMemoryStore() *MemoryStore {
+	ms := &MemoryStore{isbns: make(map[string]string)}
+	for i := range ms.shards {
+		ms.shards[i] = &memoryShard{books: make(map[string]Book)}
+	}
+
+	return ms
+}
+
+func (ms *MemoryStore) shard(id string) *memoryShard {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+
+	return ms.shards[h.Sum32()%memoryShards]
+}
+
+// lockAll takes every shard lock, always in the same order, for operations
+// that must see or change several shards atomically.
+func (ms *MemoryStore) lockAll() {
+	for _, shard := range ms.shards {
+		shard.mu.Lock()
+	}
+}
+
+func (ms *MemoryStore) unlockAll() {
+	for _, shard := range ms.shards {
+		shard.mu.Unlock()
+	}
+}
+
+func (ms *MemoryStore) rLockAll() {
+	for _, shard := range ms.shards {
+		shard.mu.RLock()
+	}
+}
+
+func (ms *MemoryStore) rUnlockAll() {
+	for _, shard := range ms.shards {
+		shard.mu.RUnlock()
+	}
+}
+
+// claimISBN records book's ISBN as taken by it, failing if another book
+// already has it.
+func (ms *MemoryStore) claimISBN(book Book) error {
+	if book.ISBN == "" {
+		return nil
+	}
+
+	ms.isbnMu.Lock()
+	defer ms.isbnMu.Unlock()
+
+	if owner, taken := ms.isbns[book.ISBN]; taken && owner != book.ID {
+		return ErrDuplicateISBN
+	}
+
+	ms.isbns[book.ISBN] = book.ID
+
+	return nil
+}
+
+func (ms *MemoryStore) releaseISBN(book Book) {
+	ms.isbnMu.Lock()
+	defer ms.isbnMu.Unlock()
+
+	if ms.isbns[book.ISBN] == book.ID {
+		delete(ms.isbns, book.ISBN)
+	}
+}
+
+// putLocked stores book, replacing any book with the same ID, and keeps the
+// ISBN index in step without checking it. It is for loading and rollback.
+func (ms *MemoryStore) putLocked(book Book) {
+	shard := ms.shard(book.ID)
+
+	if old, exist := shard.books[book.ID]; exist {
+		ms.releaseISBN(old)
+	}
+
+	shard.books[book.ID] = book.clone()
+
+	if book.ISBN != "" {
+		ms.isbnMu.Lock()
+		ms.isbns[book.ISBN] = book.ID
+		ms.isbnMu.Unlock()
+	}
+}
+
+func (ms *MemoryStore) Ready(ctx context.Context) error {
+	return nil
+}
+
+// GetAll holds every shard's read lock at once so the result is a
+// consistent snapshot rather than shards read at different moments.
+func (ms *MemoryStore) GetAll(ctx context.Context) ([]Book, error) {
+	ms.rLockAll()
+	defer ms.rUnlockAll()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return ms.allLocked(), nil
+}
+
+func (ms *MemoryStore) allLocked() []Book {
+	n := 0
+	for _, shard := range ms.shards {
+		n += len(shard.books)
+	}
+
+	books := make([]Book, 0, n)
+
+	for _, shard := range ms.shards {
+		for _, book := range shard.books {
+			books = append(books, book.clone())
+		}
+	}
+
+	return books
+}
+
+func (ms *MemoryStore) Count(ctx context.Context) (int, error) {
+	ms.rLockAll()
+	defer ms.rUnlockAll()
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, shard := range ms.shards {
+		for _, book := range shard.books {
+			if !book.deleted() {
+				n++
+			}
+		}
+	}
+
+	return n, nil
+}
+
+func (ms *MemoryStore) GetByID(ctx context.Context, id string) (Book, error) {
+	shard := ms.shard(id)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return Book{}, err
+	}
+
+	book, exist := shard.books[id]
+	if !exist {
+		return Book{}, ErrNotFound
+	}
+
+	return book.clone(), nil
+}
+
+func (ms *MemoryStore) Create(ctx context.Context, book Book) error {
+	shard := ms.shard(book.ID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return ms.createLocked(book)
+}
+
+func (ms *MemoryStore) createLocked(book Book) error {
+	shard := ms.shard(book.ID)
+
+	if _, exist := shard.books[book.ID]; exist {
+		return ErrConflict
+	}
+
+	if err := ms.claimISBN(book); err != nil {
+		return err
+	}
+
+	shard.books[book.ID] = book.clone()
+
+	return nil
+}
+
+func (ms *MemoryStore) CreateBatch(ctx context.Context, books []Book) error {
+	ms.lockAll()
+	defer ms.unlockAll()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return ms.createBatchLocked(books)
+}
+
+func (ms *MemoryStore) createBatchLocked(books []Book) error {
+	seen := make(map[string]bool, len(books))
+	seenISBN := make(map[string]bool, len(books))
+
+	ms.isbnMu.Lock()
+	defer ms.isbnMu.Unlock()
+
+	for i, book := range books {
+		if _, exist := ms.shard(book.ID).books[book.ID]; exist || seen[book.ID] {
+			return &BatchError{Index: i, Err: ErrConflict}
+		}
+		seen[book.ID] = true
+
+		if book.ISBN == "" {
+			continue
+		}
+		if _, taken := ms.isbns[book.ISBN]; taken || seenISBN[book.ISBN] {
+			return &BatchError{Index: i, Err: ErrDuplicateISBN}
+		}
+		seenISBN[book.ISBN] = true
+	}
+
+	for _, book := range books {
+		ms.shard(book.ID).books[book.ID] = book.clone()
+		if book.ISBN != "" {
+			ms.isbns[book.ISBN] = book.ID
+		}
+	}
+
+	return nil
+}
+
+func (ms *MemoryStore) Update(ctx context.Context, id string, book Book) error {
+	shard := ms.shard(id)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := ms.updateLocked(id, book)
+	return err
+}
+
+// updateLocked stores book under id and returns the book it replaced.
+func (ms *MemoryStore) updateLocked(id string, book Book) (Book, error) {
+	shard := ms.shard(id)
+
+	old, exist := shard.books[id]
+	if !exist {
+		return Book{}, ErrNotFound
+	}
+
+	if book.Version != old.Version+1 {
+		return Book{}, ErrVersionConflict
+	}
+
+	if book.ISBN != old.ISBN {
+		if err := ms.claimISBN(book); err != nil {
+			return Book{}, err
+		}
+		ms.releaseISBN(old)
+	}
+
+	shard.books[id] = book.clone()
+
+	return old, nil
+}
+
+func (ms *MemoryStore) Delete(ctx context.Context, id string) error {
+	shard := ms.shard(id)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := ms.deleteLocked(id)
+	return err
+}
+
+// deleteLocked removes id and returns the book that was stored under it.
+func (ms *MemoryStore) deleteLocked(id string) (Book, error) {
+	shard := ms.shard(id)
+
+	old, exist := shard.books[id]
+	if !exist {
+		return Book{}, ErrNotFound
+	}
+
+	delete(shard.books, id)
+	ms.releaseISBN(old)
+
+	return old, nil
+}
+
+func (ms *MemoryStore) DeleteAll(ctx context.Context) (int, error) {
+	ms.lockAll()
+	defer ms.unlockAll()
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return len(ms.deleteAllLocked()), nil
+}
+
+// deleteAllLocked empties the store and returns the books it held.
+func (ms *MemoryStore) deleteAllLocked() []Book {
+	removed := ms.allLocked()
+
+	for _, shard := range ms.shards {
+		shard.books = make(map[string]Book)
+	}
+
+	ms.isbnMu.Lock()
+	ms.isbns = make(map[string]string)
+	ms.isbnMu.Unlock()
+
+	return removed
+}
+
+func (ms *MemoryStore) SoftDeleteBatch(ctx context.Context, ids []string, deletedAt time.Time) ([]string, error) {
+	ms.lockAll()
+	defer ms.unlockAll()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return ms.softDeleteBatchLocked(ids, deletedAt), nil
+}
+
+func (ms *MemoryStore) softDeleteBatchLocked(ids []string, deletedAt time.Time) []string {
+	deleted := []string{}
+
+	for _, id := range ids {
+		shard := ms.shard(id)
+
+		book, exist := shard.books[id]
+		if !exist || book.deleted() {
+			continue
+		}
+
+		at := deletedAt
+		book.DeletedAt = &at
+		book.Version++
+		shard.books[id] = book
+		deleted = append(deleted, id)
+	}
+
+	return deleted
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// storeBackends opens an empty store of every backend that runs without a
+// server.
+func storeBackends(t *testing.T) map[string]BookStore {
+	t.Helper()
+	dir := t.TempDir()
+
+	sqlite, err := NewSQLiteStore(filepath.Join(dir, "books.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlite.Close() })
+
+	file, err := NewJSONFileStore(filepath.Join(dir, "books.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]BookStore{
+		"memory": NewMemoryStore(),
+		"sqlite": sqlite,
+		"file":   file,
+	}
+}
+
+func TestBookStoreCRUD(t *testing.T) {
+	ctx := context.Background()
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for name, store := range storeBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			book := Book{ID: "a", Name: "Emma", Author: "Austen", CreatedAt: at, UpdatedAt: at, Version: 1}
+
+			if err := store.Create(ctx, book); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Create(ctx, book); !errors.Is(err, ErrConflict) {
+				t.Errorf("duplicate Create: %v, want ErrConflict", err)
+			}
+			if got, err := store.GetByID(ctx, "a"); err != nil || got.Name != "Emma" || !got.CreatedAt.Equal(at) {
+				t.Errorf("GetByID = %+v, %v", got, err)
+			}
+			if _, err := store.GetByID(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetByID of a missing ID: %v, want ErrNotFound", err)
+			}
+
+			book.Author = "Jane Austen"
+			book.Version = 2
+			if err := store.Update(ctx, "a", book); err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+			if err := store.Update(ctx, "a", book); !errors.Is(err, ErrVersionConflict) {
+				t.Errorf("stale Update: %v, want ErrVersionConflict", err)
+			}
+			if err := store.Update(ctx, "missing", book); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Update of a missing ID: %v, want ErrNotFound", err)
+			}
+
+			if err := store.Create(ctx, Book{ID: "b", Name: "Dune", Author: "Herbert", Version: 1}); err != nil {
+				t.Fatal(err)
+			}
+			books, err := store.GetAll(ctx)
+			if err != nil || len(books) != 2 {
+				t.Fatalf("GetAll = %v, %v", books, err)
+			}
+
+			if err := store.Delete(ctx, "b"); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if err := store.Delete(ctx, "b"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("second Delete: %v, want ErrNotFound", err)
+			}
+			if got, _ := store.GetByID(ctx, "a"); got.Author != "Jane Austen" || got.Version != 2 {
+				t.Errorf("book after updates = %+v", got)
+			}
+		})
+	}
+}
+
+// singleLockStore is the map-behind-one-RWMutex design MemoryStore replaced,
+// kept here as the baseline for the benchmarks.
+type singleLockStore struct {
+	mu    sync.RWMutex
+	books map[string]Book
+}
+
+func (s *singleLockStore) Create(ctx context.Context, book Book) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exist := s.books[book.ID]; exist {
+		return ErrConflict
+	}
+
+	s.books[book.ID] = book.clone()
+	return nil
+}
+
+// benchmarkCreates runs create from parallel goroutines, each with its own
+// IDs, so only the store's locking is contended.
+func benchmarkCreates(b *testing.B, create func(context.Context, Book) error) {
+	ctx := context.Background()
+	var worker atomic.Int64
+
+	b.SetParallelism(8)
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		prefix := strconv.FormatInt(worker.Add(1), 10) + "-"
+
+		for i := 0; pb.Next(); i++ {
+			book := Book{ID: prefix + strconv.Itoa(i), Name: "N", Author: "A"}
+			if err := create(ctx, book); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkMemoryStoreSingleLock(b *testing.B) {
+	store := &singleLockStore{books: make(map[string]Book)}
+	benchmarkCreates(b, store.Create)
+}
+
+func BenchmarkMemoryStoreSharded(b *testing.B) {
+	benchmarkCreates(b, NewMemoryStore().Create)
+}
+
+func TestMemoryStoreConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryStore()
+
+	const writers, perWriter = 8, 50
+
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perWriter {
+				id := strconv.Itoa(w) + "-" + strconv.Itoa(i)
+				if err := ms.Create(ctx, Book{ID: id, Name: "N", Author: "A", ISBN: id}); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	books, err := ms.GetAll(ctx)
+	if err != nil || len(books) != writers*perWriter {
+		t.Fatalf("GetAll = %d books, %v; want %d", len(books), err, writers*perWriter)
+	}
+	if n, _ := ms.Count(ctx); n != writers*perWriter {
+		t.Errorf("Count = %d, want %d", n, writers*perWriter)
+	}
+
+	if err := ms.Create(ctx, Book{ID: "dup", ISBN: "0-1"}); err != ErrDuplicateISBN {
+		t.Errorf("create with an ISBN held in another shard: %v, want ErrDuplicateISBN", err)
+	}
+}
+
+func TestMemoryStoreHonoursContext(t *testing.T) {
+	ms := NewMemoryStore()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := ms.Create(ctx, Book{ID: "a"}); err != context.Canceled {
+		t.Errorf("Create with a cancelled context: %v", err)
+	}
+	if n, _ := ms.Count(context.Background()); n != 0 {
+		t.Errorf("Count = %d after a cancelled create, want 0", n)
+	}
+}
+
+// DeletedAt is Book's only reference field, so it stands in for any pointer,
+// slice or map field added later.
+func TestMemoryStoreReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	deletedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	want := deletedAt
+
+	book := Book{ID: "a", Name: "Emma", Author: "Austen", DeletedAt: &deletedAt, Version: 1}
+	if err := store.Create(ctx, book); err != nil {
+		t.Fatal(err)
+	}
+	*book.DeletedAt = time.Time{}
+
+	got, _ := store.GetByID(ctx, "a")
+	*got.DeletedAt = time.Time{}
+
+	all, _ := store.GetAll(ctx)
+	*all[0].DeletedAt = time.Time{}
+
+	if stored, _ := store.GetByID(ctx, "a"); !stored.DeletedAt.Equal(want) {
+		t.Errorf("stored deleted_at %v after callers changed their copies, want %v", stored.DeletedAt, want)
+	}
+
+	clone := book.clone()
+	if clone.DeletedAt == book.DeletedAt {
+		t.Error("clone shares DeletedAt with the original")
+	}
+}
+
+func TestOpenStore(t *testing.T) {
+	t.Setenv("DATABASE_URL", "")
+	dbPath := filepath.Join(t.TempDir(), "books.db")
+
+	for _, tt := range []struct {
+		backend, dbPath string
+		want            string
+	}{
+		{"", "", "*main.MemoryStore"},
+		{"memory", dbPath, "*main.MemoryStore"},
+		{"", dbPath, "*main.SQLiteStore"},
+		{"sqlite", dbPath, "*main.SQLiteStore"},
+	} {
+		store, err := openStore(tt.backend, tt.dbPath, "")
+		if err != nil {
+			t.Errorf("openStore(%q, %q): %v", tt.backend, tt.dbPath, err)
+			continue
+		}
+		if got := fmt.Sprintf("%T", store); got != tt.want {
+			t.Errorf("openStore(%q, %q) = %s, want %s", tt.backend, tt.dbPath, got, tt.want)
+		}
+		if closer, ok := store.(interface{ Close() error }); ok {
+			closer.Close()
+		}
+	}
+
+	for _, backend := range []string{"postgres", "file", "mongo"} {
+		if _, err := openStore(backend, "", ""); err == nil {
+			t.Errorf("openStore(%q) without its settings succeeded", backend)
+		}
+	}
+}
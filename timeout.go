@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultRequestTimeout = 30 * time.Second
+
+const requestTimeoutKey = "request_timeout"
+
+// requestTimeout cancels each request's context after d. Handlers pass the
+// context down to storage, which then gives up with the context's error, so
+// the handler stops before changing anything and nothing is written after
+// the 503. The response still goes through every outer middleware, so the
+// access log and metrics record what the client actually got.
+func requestTimeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if streamsResponse(c.Request) {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+
+		c.Set(requestTimeoutKey, d)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		// A handler that never touched storage can return after the deadline
+		// without having written anything.
+		if ctx.Err() != nil && !c.Writer.Written() {
+			respondTimeout(c)
+		}
+	}
+}
+
+// isContextError reports whether err is the store giving up because the
+// request was cancelled or ran out of time.
+func isContextError(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)
+}
+
+// respondTimeout answers 503 for a request whose context is done. It isn't
+// logged as an error: the server is fine, the request was just too slow or
+// the client went away.
+func respondTimeout(c *gin.Context) {
+	message := "Request was cancelled before it completed"
+	if d := c.GetDuration(requestTimeoutKey); d > 0 && errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+		message = "Request took longer than " + d.String() + " to process"
+	}
+
+	respondError(c, http.StatusServiceUnavailable, CodeRequestTimeout, message)
+}
+
+// streamsResponse reports whether r is for an endpoint that writes its body
+// incrementally over an open-ended time, so no deadline applies to it.
+func streamsResponse(r *http.Request) bool {
+	path := strings.TrimSuffix(r.URL.Path, ".csv")
+
+	// The event streams stay open indefinitely, and an export of a large
+	// store may take longer than any sensible request deadline.
+	return strings.HasSuffix(path, "/book/export") ||
+		strings.HasSuffix(path, "/book/events") ||
+		strings.HasSuffix(path, "/book/stream")
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+// slowStore holds every read and create for delay, giving up early with
+// ctx's error like a real backend would.
+type slowStore struct {
+	BookStore
+	delay time.Duration
+}
+
+func (s *slowStore) wait(ctx context.Context) error {
+	select {
+	case <-time.After(s.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *slowStore) GetAll(ctx context.Context) ([]Book, error) {
+	if err := s.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.BookStore.GetAll(ctx)
+}
+
+func (s *slowStore) Create(ctx context.Context, book Book) error {
+	if err := s.wait(ctx); err != nil {
+		return err
+	}
+
+	return s.BookStore.Create(ctx, book)
+}
+
+func TestRequestTimeout(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	inner := NewMemoryStore()
+
+	bs := newBookService(&slowStore{BookStore: inner, delay: time.Second}, logger)
+	bs.RequestTimeout = 20 * time.Millisecond
+	bs.ready.Store(true)
+	router := setupRouter(bs)
+
+	w := do(t, router, http.MethodPost, "/v1/book", `{"name":"Slow","author":"A"}`)
+	if w.Code != http.StatusServiceUnavailable || errorCode(t, w) != CodeRequestTimeout {
+		t.Fatalf("status %d, body %s; want 503 %s", w.Code, w.Body, CodeRequestTimeout)
+	}
+	if !strings.Contains(w.Body.String(), "20ms") {
+		t.Errorf("body %s doesn't name the timeout", w.Body)
+	}
+
+	if n, _ := inner.Count(context.Background()); n != 0 {
+		t.Errorf("%d books stored by the timed out create, want 0", n)
+	}
+
+	var logged []any
+	for _, entry := range hook.AllEntries() {
+		if entry.Message == "Request handled" {
+			logged = append(logged, entry.Data["status"])
+		}
+		if entry.Message == "Error when accessing storage" {
+			t.Errorf("timeout logged as a storage error")
+		}
+	}
+	if len(logged) != 1 || logged[0] != http.StatusServiceUnavailable {
+		t.Errorf("access log statuses %v, want [503]", logged)
+	}
+
+	want := `http_requests_total{method="POST",path="/v1/book",status="503"} 1`
+	if body := scrape(t, router); !strings.Contains(body, want) {
+		t.Errorf("/metrics has no %q", want)
+	}
+}
+
+func TestRequestTimeoutNotReached(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	bs := newBookService(&slowStore{BookStore: NewMemoryStore(), delay: time.Millisecond}, logger)
+	bs.RequestTimeout = time.Second
+	router := setupRouter(bs)
+
+	postBook(t, router, `{"name":"Quick","author":"A"}`)
+
+	if w := do(t, router, http.MethodGet, "/v1/book", ""); w.Code != http.StatusOK {
+		t.Errorf("GET /v1/book: status %d, body %s", w.Code, w.Body)
+	}
+}
+
+func TestStreamsResponse(t *testing.T) {
+	for path, want := range map[string]bool{
+		"/v1/book/export":     true,
+		"/v1/book/export.csv": true,
+		"/v1/book/events":     true,
+		"/book/stream":        true,
+		"/v1/book":            false,
+		"/v1/book/count":      false,
+	} {
+		if got := streamsResponse(httptest.NewRequest(http.MethodGet, path, nil)); got != want {
+			t.Errorf("streamsResponse(%s) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestRequestTimeoutSlowHandler(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	bs := newBookService(NewMemoryStore(), logger)
+	bs.RequestTimeout = 20 * time.Millisecond
+	router := setupRouter(bs)
+
+	router.GET("/v1/sleep", func(c *gin.Context) { time.Sleep(50 * time.Millisecond) })
+	router.GET("/v1/wait", func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+		case <-time.After(time.Second):
+			c.Status(http.StatusOK)
+		}
+	})
+	router.GET("/v1/quick", func(c *gin.Context) {
+		c.String(http.StatusOK, "done")
+		time.Sleep(50 * time.Millisecond)
+	})
+
+	for _, path := range []string{"/v1/sleep", "/v1/wait"} {
+		start := time.Now()
+		w := do(t, router, http.MethodGet, path, "")
+		if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "longer than 20ms") {
+			t.Errorf("%s: status %d, body %s; want 503 naming the timeout", path, w.Code, w.Body)
+		}
+		if path == "/v1/wait" && time.Since(start) > 500*time.Millisecond {
+			t.Errorf("%s: took %v, want the handler released at the deadline", path, time.Since(start))
+		}
+	}
+
+	if w := do(t, router, http.MethodGet, "/v1/quick", ""); w.Code != http.StatusOK || w.Body.String() != "done" {
+		t.Errorf("response written before the deadline: status %d, body %s", w.Code, w.Body)
+	}
+}
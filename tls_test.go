@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeSelfSignedCert writes a certificate for 127.0.0.1 and its key to dir
+// and returns their paths along with a pool that trusts the certificate.
+func writeSelfSignedCert(t *testing.T, dir string) (certFile, keyFile string, roots *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, _ := x509.ParseCertificate(der)
+	roots = x509.NewCertPool()
+	roots.AddCert(cert)
+
+	return certFile, keyFile, roots
+}
+
+func TestLoadTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeSelfSignedCert(t, dir)
+	garbage := filepath.Join(dir, "garbage.pem")
+	os.WriteFile(garbage, []byte("not a certificate"), 0o600)
+
+	if config, err := loadTLSConfig("", "", defaultTLSMinVersion); config != nil || err != nil {
+		t.Errorf("no files: %v, %v; want plain HTTP", config, err)
+	}
+
+	config, err := loadTLSConfig(certFile, keyFile, defaultTLSMinVersion)
+	if err != nil || len(config.Certificates) != 1 || config.MinVersion != tls.VersionTLS12 {
+		t.Errorf("valid pair: %+v, %v", config, err)
+	}
+
+	for _, tt := range []struct {
+		name, cert, key, version, wantError string
+	}{
+		{"cert only", certFile, "", "1.2", "set together"},
+		{"key only", "", keyFile, "1.2", "set together"},
+		{"bad version", certFile, keyFile, "1.4", "invalid TLS minimum version"},
+		{"missing file", filepath.Join(dir, "missing.pem"), keyFile, "1.2", "no such file"},
+		{"unparsable cert", garbage, keyFile, "1.2", "loading TLS key pair"},
+		{"swapped files", keyFile, certFile, "1.2", "loading TLS key pair"},
+	} {
+		if _, err := loadTLSConfig(tt.cert, tt.key, tt.version); err == nil || !strings.Contains(err.Error(), tt.wantError) {
+			t.Errorf("%s: %v, want an error mentioning %q", tt.name, err, tt.wantError)
+		}
+	}
+}
+
+func TestMainServesTLS(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts the server binary")
+	}
+
+	certFile, keyFile, roots := writeSelfSignedCert(t, t.TempDir())
+	addr, _ := startMain(t, mainCommand("-addr", "127.0.0.1:0", "-backend", "memory",
+		"-tls-cert", certFile, "-tls-key", keyFile, "-tls-min-version", "1.3"))
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	resp, err := client.Get("https://" + addr + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.TLS == nil || resp.TLS.Version != tls.VersionTLS13 {
+		t.Errorf("HTTPS /healthz: status %d, TLS %+v", resp.StatusCode, resp.TLS)
+	}
+
+	old := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, MaxVersion: tls.VersionTLS12}}}
+	if resp, err := old.Get("https://" + addr + "/healthz"); err == nil {
+		resp.Body.Close()
+		t.Error("TLS 1.2 client was served despite -tls-min-version 1.3")
+	}
+
+	out, err := mainCommand("-addr", "127.0.0.1:0", "-tls-cert", certFile, "-tls-key", certFile).CombinedOutput()
+	if err == nil || !bytes.Contains(out, []byte("Error when loading the TLS configuration")) {
+		t.Errorf("unusable key: exited with %v, output %s", err, out)
+	}
+}
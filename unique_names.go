@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// uniqueNameStore rejects creates and updates that would give two books the
+// same name, compared case-insensitively. Its mutex serialises every write
+// that can change a name, so the check and the write can't interleave with
+// another request's.
+type uniqueNameStore struct {
+	BookStore
+	mu sync.Mutex
+}
+
+func newUniqueNameStore(store BookStore) *uniqueNameStore {
+	return &uniqueNameStore{BookStore: store}
+}
+
+// nameOwners maps each stored name, soft-deleted books included, to the ID
+// of the book that has it.
+func (us *uniqueNameStore) nameOwners(ctx context.Context) (map[string]string, error) {
+	books, err := us.BookStore.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make(map[string]string, len(books))
+	for _, book := range books {
+		owners[nameKey(book.Name)] = book.ID
+	}
+
+	return owners, nil
+}
+
+func nameKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func (us *uniqueNameStore) Create(ctx context.Context, book Book) error {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	owners, err := us.nameOwners(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, taken := owners[nameKey(book.Name)]; taken {
+		return ErrDuplicateName
+	}
+
+	return us.BookStore.Create(ctx, book)
+}
+
+func (us *uniqueNameStore) CreateBatch(ctx context.Context, books []Book) error {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	owners, err := us.nameOwners(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i, book := range books {
+		key := nameKey(book.Name)
+		if _, taken := owners[key]; taken {
+			return &BatchError{Index: i, Err: ErrDuplicateName}
+		}
+		owners[key] = book.ID
+	}
+
+	return us.BookStore.CreateBatch(ctx, books)
+}
+
+func (us *uniqueNameStore) Update(ctx context.Context, id string, book Book) error {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	owners, err := us.nameOwners(ctx)
+	if err != nil {
+		return err
+	}
+
+	if owner, taken := owners[nameKey(book.Name)]; taken && owner != id {
+		return ErrDuplicateName
+	}
+
+	return us.BookStore.Update(ctx, id, book)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestUniqueNames(t *testing.T) {
+	router := setupRouter(newStoreService(t, newUniqueNameStore(NewMemoryStore())))
+	emma := postBook(t, router, `{"name":"Emma","author":"Austen"}`)
+	dune := postBook(t, router, `{"name":"Dune","author":"Herbert"}`)
+
+	for _, tt := range []struct{ method, path, body string }{
+		{http.MethodPost, "/v1/book", `{"name":" EMMA ","author":"Someone"}`},
+		{http.MethodPut, "/v1/book/" + dune.ID, `{"name":"emma","author":"Herbert"}`},
+		{http.MethodPatch, "/v1/book/" + dune.ID, `{"name":"Emma"}`},
+		{http.MethodPost, "/v1/books/batch", `[{"name":"Sanditon","author":"Austen"},{"name":"Emma","author":"Austen"}]`},
+	} {
+		w := do(t, router, tt.method, tt.path, tt.body)
+		if w.Code != http.StatusConflict || errorCode(t, w) != CodeConflict {
+			t.Errorf("%s %s with a taken name: status %d, body %s; want 409", tt.method, tt.path, w.Code, w.Body)
+		}
+	}
+	if total := decode[BookPage](t, do(t, router, http.MethodGet, "/v1/book", "")).Total; total != 2 {
+		t.Errorf("%d books after the rejected writes, want 2", total)
+	}
+
+	if w := do(t, router, http.MethodPut, "/v1/book/"+emma.ID, `{"name":"Emma","author":"Jane Austen"}`); w.Code != http.StatusOK {
+		t.Errorf("update keeping its own name: status %d, body %s", w.Code, w.Body)
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		created int
+	)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if w := do(t, router, http.MethodPost, "/v1/book", `{"name":"Persuasion","author":"Austen"}`); w.Code == http.StatusCreated {
+				mu.Lock()
+				created++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if created != 1 {
+		t.Errorf("%d concurrent creates of one name succeeded, want 1", created)
+	}
+}
+
+func TestUniqueNamesDisabled(t *testing.T) {
+	router := newSeededRouter(t)
+
+	if w := do(t, router, http.MethodPost, "/v1/book", `{"name":"Emma","author":"Someone"}`); w.Code != http.StatusCreated {
+		t.Errorf("duplicate name without -unique-names: status %d, body %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func registerValidation() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	}
+}
+
+// unknownFieldError is returned by decodeStrictJSON for a key that doesn't
+// map to any field of the target.
+type unknownFieldError struct {
+	Field string
+}
+
+func (e *unknownFieldError) Error() string {
+	return fmt.Sprintf("unknown field %q", e.Field)
+}
+
+// decodeStrictJSON decodes the request body into obj, rejecting keys obj
+// has no field for so typos don't go unnoticed.
+func decodeStrictJSON(c *gin.Context, obj any) error {
+	return strictDecode(c.Request.Body, obj)
+}
+
+func strictDecode(r io.Reader, obj any) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(obj)
+
+	// encoding/json has no typed error for this case, only the message.
+	if field, ok := strings.CutPrefix(fmt.Sprint(err), "json: unknown field "); ok {
+		return &unknownFieldError{Field: strings.Trim(field, `"`)}
+	}
+	if err != nil {
+		return err
+	}
+
+	return expectEOF(dec)
+}
+
+var errTrailingData = errors.New("Request body must hold a single JSON value")
+
+// expectEOF fails with errTrailingData unless dec has nothing left to read
+// but whitespace, so a valid value followed by garbage or by a second value
+// isn't accepted as the first one alone.
+func expectEOF(dec *json.Decoder) error {
+	var extra json.RawMessage
+
+	err := dec.Decode(&extra)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return err
+	}
+
+	return errTrailingData
+}
+
+// bindStrictJSON is ShouldBindJSON with decodeStrictJSON's unknown key check.
+func bindStrictJSON(c *gin.Context, obj any) error {
+	if err := decodeStrictJSON(c, obj); err != nil {
+		return err
+	}
+
+	return binding.Validator.ValidateStruct(obj)
+}
+
+func (bs *BookService) bindError(err error, c *gin.Context) {
+	if respondBindError(c, err) {
+		bs.logError(err, c, "Error when decoding JSON")
+	}
+}
+
+// respondBindError answers a failed bind and reports whether the body was
+// malformed rather than merely invalid, which callers log.
+func respondBindError(c *gin.Context, err error) (malformed bool) {
+	// The JSON decoder only reports a bare io.EOF when the body was empty
+	// or whitespace; a truncated document is io.ErrUnexpectedEOF.
+	if errors.Is(err, io.EOF) {
+		respondError(c, http.StatusBadRequest, CodeBadRequest, "Request body is required")
+		return false
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respondBodyTooLarge(c, tooLarge.Limit)
+		return false
+	}
+
+	if errors.Is(err, errTrailingData) {
+		respondError(c, http.StatusBadRequest, CodeBadRequest, errTrailingData.Error())
+		return true
+	}
+
+	var unknownField *unknownFieldError
+	if errors.As(err, &unknownField) {
+		message := fmt.Sprintf("%s is not a known field", unknownField.Field)
+		respondErrorDetails(c, http.StatusBadRequest, CodeValidationFailed,
+			"Validation failed: "+message, []string{message})
+		return false
+	}
+
+	fields, ok := validationFields(err)
+	if !ok {
+		respondError(c, http.StatusBadRequest, CodeInvalidJSON, "Invalid JSON format")
+		return true
+	}
+
+	respondErrorDetails(c, http.StatusBadRequest, CodeValidationFailed,
+		"Validation failed: "+strings.Join(fields, "; "), fields)
+	return false
+}
+
+func validationFields(err error) ([]string, bool) {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return nil, false
+	}
+
+	fields := make([]string, 0, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		fields = append(fields, validationMessage(fieldErr))
+	}
+
+	return fields, true
+}
+
+func validationMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", fieldErr.Field())
+	case "min":
+		if fieldErr.Param() == "1" {
+			return fmt.Sprintf("%s must not be empty", fieldErr.Field())
+		}
+		return fmt.Sprintf("%s must be at least %s characters", fieldErr.Field(), fieldErr.Param())
+	case "max":
+		return fmt.Sprintf("%s must be at most %s characters", fieldErr.Field(), fieldErr.Param())
+	default:
+		return fmt.Sprintf("%s failed on %s", fieldErr.Field(), fieldErr.Tag())
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// errorDetails returns the details of an error envelope as strings.
+func errorDetails(t *testing.T, w *httptest.ResponseRecorder) []string {
+	t.Helper()
+
+	return decode[struct {
+		Error struct {
+			Details []string `json:"details"`
+		} `json:"error"`
+	}](t, w).Error.Details
+}
+
+func TestCreateValidation(t *testing.T) {
+	router := setupRouter(newTestService(t))
+	long := strings.Repeat("x", 201)
+
+	for _, tt := range []struct {
+		body string
+		want []string
+	}{
+		{`{"author":"Austen"}`, []string{"name is required"}},
+		{`{"id":"1"}`, []string{"name is required", "author is required"}},
+		{`{"name":"` + long + `","author":"Austen"}`, []string{"name must be at most 200 characters"}},
+	} {
+		w := do(t, router, http.MethodPost, "/v1/book", tt.body)
+		if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeValidationFailed {
+			t.Errorf("%s: status %d, body %s", tt.body, w.Code, w.Body)
+			continue
+		}
+		if got := errorDetails(t, w); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: details %q, want %q", tt.body, got, tt.want)
+		}
+	}
+
+	if w := do(t, router, http.MethodGet, "/v1/book", ""); decode[BookPage](t, w).Total != 0 {
+		t.Errorf("invalid books were stored: %s", w.Body)
+	}
+}
+
+func TestBlankNameRejected(t *testing.T) {
+	router := newSeededRouter(t)
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodPost, "/v1/book"},
+		{http.MethodPut, "/v1/book/" + seedID},
+	} {
+		w := do(t, router, req.method, req.path, `{"name":"","author":"Austen"}`)
+		if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeValidationFailed {
+			t.Errorf("%s: status %d, body %s", req.method, w.Code, w.Body)
+			continue
+		}
+		if details := errorDetails(t, w); len(details) != 1 || details[0] != "name is required" {
+			t.Errorf("%s: details %q, want the name named", req.method, details)
+		}
+	}
+
+	if got := decode[Book](t, do(t, router, http.MethodGet, "/v1/book/"+seedID, "")); got.Name != "Emma" {
+		t.Errorf("rejected update changed the book: %+v", got)
+	}
+}
+
+func TestUnknownFieldsRejected(t *testing.T) {
+	router := newSeededRouter(t)
+
+	for _, tt := range []struct{ method, path, body, field string }{
+		{http.MethodPost, "/v1/book", `{"titel":"x","name":"Emma","author":"Austen"}`, "titel"},
+		{http.MethodPut, "/v1/book/" + seedID, `{"name":"Emma","author":"Austen","autor":"x"}`, "autor"},
+		{http.MethodPatch, "/v1/book/" + seedID, `{"nmae":"x"}`, "nmae"},
+		{http.MethodPost, "/v1/books/batch", `[{"name":"Dune","author":"Herbert","pages":412}]`, "pages"},
+	} {
+		w := do(t, router, tt.method, tt.path, tt.body)
+		want := tt.field + " is not a known field"
+		if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeValidationFailed || !strings.Contains(w.Body.String(), want) {
+			t.Errorf("%s %s: status %d, body %s; want 400 with %q", tt.method, tt.path, w.Code, w.Body, want)
+		}
+	}
+
+	if got := decode[Book](t, do(t, router, http.MethodGet, "/v1/book/"+seedID, "")); got.Version != 1 {
+		t.Errorf("rejected updates changed the book: %+v", got)
+	}
+
+	// Binding errors still name the failing field alongside strict decoding.
+	w := do(t, router, http.MethodPost, "/v1/book", `{"name":"Emma"}`)
+	if details := errorDetails(t, w); w.Code != http.StatusBadRequest || len(details) != 1 || !strings.Contains(details[0], "author") {
+		t.Errorf("missing author: status %d, body %s", w.Code, w.Body)
+	}
+}
+
+func TestEmptyBodyRejected(t *testing.T) {
+	router := newSeededRouter(t)
+
+	for _, tt := range []struct{ method, path string }{
+		{http.MethodPost, "/v1/book"},
+		{http.MethodPut, "/v1/book/" + seedID},
+		{http.MethodPatch, "/v1/book/" + seedID},
+		{http.MethodPost, "/v1/author"},
+	} {
+		for _, body := range []string{"", "   ", "\n\t\r\n"} {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeBadRequest ||
+				!strings.Contains(w.Body.String(), "Request body is required") {
+				t.Errorf("%s %s with body %q: status %d, body %s", tt.method, tt.path, body, w.Code, w.Body)
+			}
+		}
+	}
+
+	// A truncated document is malformed JSON, not a missing body.
+	if w := do(t, router, http.MethodPost, "/v1/book", `{"name":`); errorCode(t, w) != CodeInvalidJSON {
+		t.Errorf("truncated body: status %d, body %s", w.Code, w.Body)
+	}
+}
+
+func TestTrailingDataRejected(t *testing.T) {
+	router := newSeededRouter(t)
+	book := `{"name":"Emma","author":"Austen"}`
+
+	for _, tt := range []struct{ method, path, contentType, body string }{
+		{http.MethodPost, "/v1/book", "application/json", book},
+		{http.MethodPut, "/v1/book/" + seedID, "application/json", book},
+		{http.MethodPatch, "/v1/book/" + seedID, "application/json", `{"author":"Jane Austen"}`},
+		{http.MethodPatch, "/v1/book/" + seedID, mimeMergePatch, `{"author":"Jane Austen"}`},
+		{http.MethodPost, "/v1/books/batch", "application/json", `[` + book + `]`},
+		{http.MethodPost, "/v1/book/import", "application/json", `[` + book + `]`},
+		{http.MethodPost, "/v1/author", "application/json", `{"name":"Austen"}`},
+	} {
+		for _, trailing := range []string{" garbage", " " + tt.body, "}"} {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body+trailing))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeBadRequest {
+				t.Errorf("%s %s %s with %q after the body: status %d, body %s", tt.method, tt.path, tt.contentType, trailing, w.Code, w.Body)
+			}
+		}
+	}
+
+	if w := do(t, router, http.MethodPost, "/v1/book", book+"\n\t "); w.Code != http.StatusCreated {
+		t.Errorf("trailing whitespace: status %d, body %s", w.Code, w.Body)
+	}
+}
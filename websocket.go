@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+)
+
+// checkOrigin accepts same-origin connections and origins allowed by the
+// CORS settings. Browsers send cookies on WebSocket upgrades from any page,
+// so other origins are refused.
+func (bs *BookService) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || bs.CORS.allowsOrigin(origin) {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// streamEventsWS upgrades to a WebSocket and sends each book change as a
+// JSON BookEvent text message. Clients only ever receive; a client that
+// falls too far behind is disconnected with 1013, and every client with 1001
+// when the server shuts down.
+func (bs *BookService) streamEventsWS(c *gin.Context) {
+	upgrader := websocket.Upgrader{CheckOrigin: bs.checkOrigin}
+
+	// Subscribing before the handshake completes means a client sees every
+	// event published after its Dial returns.
+	events, unsubscribe := bs.Events.Subscribe()
+	defer unsubscribe()
+
+	// Upgrade writes the error response itself when it fails.
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// Reading is needed to handle pings, pongs and the close handshake;
+	// anything else the client sends is ignored.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		})
+
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				message := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow")
+				select {
+				case <-bs.Events.Done():
+					message = websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+				default:
+				}
+
+				conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(wsWriteWait))
+				return
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+
+		case <-closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocketStream(t *testing.T) {
+	srv, url := startServer(t, newTestService(t))
+	wsURL := "ws" + strings.TrimPrefix(url, "http") + "/v1/book/events"
+
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Origin": {"https://evil.example"}}); err == nil {
+		t.Error("connection from a foreign origin was accepted")
+	} else if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("foreign origin: %v", err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	created, err := http.Post(url+"/v1/book", "application/json", strings.NewReader(`{"name":"Emma","author":"Austen"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	created.Body.Close()
+
+	var event BookEvent
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != eventCreated || event.Book.Name != "Emma" {
+		t.Errorf("event %+v", event)
+	}
+
+	shutdownQuickly(t, srv)
+
+	// Shutdown doesn't track hijacked connections, so only the hub closing
+	// ends this one.
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("after shutdown: %v, want close 1001", err)
+	}
+}
+
+func TestWebSocketBroadcast(t *testing.T) {
+	_, url := startServer(t, newTestService(t))
+	wsURL := "ws" + strings.TrimPrefix(url, "http") + "/v1/book/events"
+
+	var clients []*websocket.Conn
+	for range 2 {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		clients = append(clients, conn)
+	}
+
+	send := func(method, path, body string) {
+		req, _ := http.NewRequest(method, url+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	send(http.MethodPost, "/v1/book", `{"id":"`+seedID+`","name":"Emma","author":"Austen"}`)
+	send(http.MethodPatch, "/v1/book/"+seedID, `{"author":"Jane Austen"}`)
+	send(http.MethodDelete, "/v1/book/"+seedID, "")
+
+	want := []string{
+		`{"type":"created","book":{"id":"` + seedID + `","name":"Emma","author":"Austen"`,
+		`{"type":"updated","book":{"id":"` + seedID + `","name":"Emma","author":"Jane Austen"`,
+		`{"type":"deleted","book":{"id":"` + seedID + `"`,
+	}
+	for i, conn := range clients {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		for _, prefix := range want {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("client %d: %v", i, err)
+			}
+			if !strings.HasPrefix(string(msg), prefix) {
+				t.Errorf("client %d: event %s, want it to start with %s", i, msg, prefix)
+			}
+		}
+	}
+}